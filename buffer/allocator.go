@@ -95,3 +95,43 @@ func (r *RecvByteBufAllocator) Record(readBytes int) {
 		r.decreaseNow = false
 	}
 }
+
+// DatagramAllocator copies received datagrams out of a reused read buffer into slices of a
+// shared chunk, so reading small datagrams does not allocate once per datagram. A chunk stays
+// alive as long as any of its buffers does. It is not safe for concurrent use.
+type DatagramAllocator struct {
+	chunkSize int
+	chunk     []byte
+}
+
+func NewDatagramAllocator(chunkSize int) *DatagramAllocator {
+	if chunkSize <= 0 {
+		chunkSize = DefaultMaximum
+	}
+
+	return &DatagramAllocator{chunkSize: chunkSize}
+}
+
+// Allocate returns a buffer holding a copy of b, ready to be read.
+func (a *DatagramAllocator) Allocate(b []byte) ByteBuffer {
+	// a ByteBuf needs some capacity, even for an empty datagram
+	size := len(b) + 1
+
+	var buf []byte
+	if size > a.chunkSize/4 {
+		// large datagrams would waste most of a chunk
+		buf = make([]byte, size)
+	} else {
+		if len(a.chunk) < size {
+			a.chunk = make([]byte, a.chunkSize)
+		}
+
+		// the capacity is capped, so growing the buffer never overwrites the next one
+		buf = a.chunk[:size:size]
+		a.chunk = a.chunk[size:]
+	}
+
+	copy(buf, b)
+
+	return NewByteBuf(buf, 0, len(b))
+}
@@ -0,0 +1,38 @@
+package buffer
+
+import "testing"
+
+func TestDatagramAllocator(t *testing.T) {
+	alloc := NewDatagramAllocator(64)
+
+	first := alloc.Allocate([]byte("abc"))
+	second := alloc.Allocate([]byte("def"))
+
+	// the buffers have room to write, without overwriting the next one
+	first.WriteByte('!')
+
+	if s := string(first.ReadBytes(first.ReadableBytes())); s != "abc!" {
+		t.Fatalf("expected abc!, got %q", s)
+	}
+
+	second.WriteBytes([]byte("X"))
+
+	if s := string(second.ReadBytes(second.ReadableBytes())); s != "defX" {
+		t.Fatalf("expected defX, got %q", s)
+	}
+
+	// large datagrams get a buffer of their own
+	large := alloc.Allocate(make([]byte, 32))
+	large.WriteBytes([]byte("Z"))
+
+	if b := large.ReadBytes(large.ReadableBytes()); len(b) != 33 || b[32] != 'Z' {
+		t.Fatalf("expected 32 bytes and Z, got %q", b)
+	}
+
+	empty := alloc.Allocate(nil)
+	empty.WriteByte('X')
+
+	if s := string(empty.ReadBytes(empty.ReadableBytes())); s != "X" {
+		t.Fatalf("expected X, got %q", s)
+	}
+}
@@ -5,6 +5,7 @@ import "net"
 type DatagramPacket struct {
 	bf    ByteBuffer
 	raddr *net.UDPAddr
+	laddr *net.UDPAddr
 }
 
 func NewDatagramPacket(raddr *net.UDPAddr, bf ByteBuffer) *DatagramPacket {
@@ -14,6 +15,16 @@ func NewDatagramPacket(raddr *net.UDPAddr, bf ByteBuffer) *DatagramPacket {
 	}
 }
 
+// NewAddressedDatagramPacket creates a packet which also carries the local address it arrived on,
+// or should be sent from.
+func NewAddressedDatagramPacket(laddr, raddr *net.UDPAddr, bf ByteBuffer) *DatagramPacket {
+	return &DatagramPacket{
+		bf:    bf,
+		raddr: raddr,
+		laddr: laddr,
+	}
+}
+
 func (packet *DatagramPacket) ByteBuf() ByteBuffer {
 	return packet.bf
 }
@@ -21,3 +32,8 @@ func (packet *DatagramPacket) ByteBuf() ByteBuffer {
 func (packet *DatagramPacket) RemoteAddress() *net.UDPAddr {
 	return packet.raddr
 }
+
+// LocalAddress returns the local address of the packet, nil unless option.UDPPacketInfo is set.
+func (packet *DatagramPacket) LocalAddress() *net.UDPAddr {
+	return packet.laddr
+}
@@ -3,6 +3,7 @@ package channel
 import (
 	"fmt"
 	"net"
+	"time"
 )
 
 type Initializer func(ch Channel)
@@ -19,3 +20,38 @@ type Channel interface {
 	Close()
 	fmt.Stringer
 }
+
+// FlowControlled is a channel which can pause reading, and tells whether the bytes queued for writing
+// stay below its high water mark. Crossing the water marks fires a WritabilityChangedEvent.
+type FlowControlled interface {
+	SetAutoRead(autoRead bool)
+	IsAutoRead() bool
+	IsWritable() bool
+}
+
+// HalfClosable is a channel which can shut down its output while it still reads, and stay open once
+// the peer shut down its output, firing a ChannelInputShutdownEvent.
+type HalfClosable interface {
+	SetAllowHalfClosure(allow bool)
+	CloseWrite()
+}
+
+// WritabilityChangedEvent is fired as user event when the bytes queued for writing on a channel
+// rise above its high water mark, or fall below its low one.
+type WritabilityChangedEvent struct {
+	Writable bool
+}
+
+// ChannelInputShutdownEvent is fired as user event when the peer shut down its output, on a channel
+// allowing half closure.
+type ChannelInputShutdownEvent struct{}
+
+// FromConn wraps a connection created outside of ngio into a channel. *net.UDPConn becomes
+// a UDPChannel, any other conn is served as a stream by a TCPChannel without deadlines.
+func FromConn(conn net.Conn) Channel {
+	if udpConn, ok := conn.(*net.UDPConn); ok {
+		return NewUDPChannel(udpConn, nil)
+	}
+
+	return NewTCPChannel(conn, time.Duration(0), time.Duration(0))
+}
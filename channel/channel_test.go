@@ -0,0 +1,62 @@
+package channel
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFromConn(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	if _, ok := FromConn(serverConn).(*TCPChannel); !ok {
+		t.Fatal("expected a TCPChannel for a stream conn")
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, ok := FromConn(conn).(*UDPChannel)
+	if !ok {
+		t.Fatal("expected a UDPChannel for a *net.UDPConn")
+	}
+
+	// served with the default options
+	recorder := &recordHandler{readC: make(chan interface{}, 1)}
+	ch.Pipeline().AddLast("record", recorder)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- ch.Serve()
+	}()
+
+	peer, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	if _, err := peer.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-recorder.readC:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the datagram")
+	}
+
+	ch.Close()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for Serve to return")
+	}
+}
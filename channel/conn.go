@@ -0,0 +1,285 @@
+package channel
+
+import (
+	"errors"
+	"io"
+	"net"
+	"ngio/buffer"
+	"sync"
+	"time"
+)
+
+var (
+	ErrConnNotAdded = errors.New("conn: not added to a pipeline")
+)
+
+// the water marks of the bytes waiting for Read
+const (
+	connLowWaterMark  = 32 * 1024
+	connHighWaterMark = 64 * 1024
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string {
+	return "conn: i/o timeout"
+}
+
+func (timeoutError) Timeout() bool {
+	return true
+}
+
+func (timeoutError) Temporary() bool {
+	return true
+}
+
+// Conn presents a channel as a net.Conn, at the point of the pipeline where it is added.
+// Bytes read by the handlers before it are returned by Read, bytes passed to Write go through
+// the handlers before it to the channel. Other messages are passed on unchanged. A FlowControlled
+// channel stops reading while more than 64 KiB wait for Read, and reads again once they fell to 32 KiB.
+//
+//	conn := channel.NewConn()
+//	ch.Pipeline().AddLast("conn", conn)
+//	go http.Serve(listener, ...) // or any library taking a net.Conn
+type Conn struct {
+	ctx           *Context
+	mu            sync.Mutex
+	pending       [][]byte
+	buffered      int
+	paused        bool
+	readableC     chan struct{}
+	closed        bool
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func NewConn() *Conn {
+	return &Conn{
+		readableC: make(chan struct{}, 1),
+	}
+}
+
+func (c *Conn) HandlerAdded(ctx *Context) {
+	c.mu.Lock()
+	c.ctx = ctx
+	c.mu.Unlock()
+}
+
+func (c *Conn) HandlerRemoved(ctx *Context) {
+	c.shutdown()
+
+	// the bytes left are read by nobody, the channel must not stay paused for them
+	c.mu.Lock()
+	paused := c.paused
+	c.paused = false
+	c.mu.Unlock()
+
+	if paused {
+		setAutoRead(ctx, true)
+	}
+}
+
+func (c *Conn) ChannelRead(ctx *Context, msg interface{}) {
+	bf, ok := msg.(buffer.ByteBuffer)
+	if !ok {
+		ctx.FireReadHandler(msg)
+		return
+	}
+
+	// copy the readable bytes, the buffer may be reused once ChannelRead returns
+	b := make([]byte, bf.ReadableBytes())
+	copy(b, bf.ReadBytes(len(b)))
+
+	c.mu.Lock()
+	c.pending = append(c.pending, b)
+	c.buffered += len(b)
+	pause := !c.paused && c.buffered > connHighWaterMark
+	if pause {
+		c.paused = true
+	}
+	c.mu.Unlock()
+
+	if pause {
+		setAutoRead(ctx, false)
+	}
+
+	c.notify()
+}
+
+func (c *Conn) ChannelInActive(ctx *Context) {
+	c.shutdown()
+	ctx.FireInActiveHandler()
+}
+
+func (c *Conn) Read(b []byte) (n int, err error) {
+	for {
+		c.mu.Lock()
+
+		if len(c.pending) > 0 {
+			n = copy(b, c.pending[0])
+			if n == len(c.pending[0]) {
+				c.pending = c.pending[1:]
+			} else {
+				c.pending[0] = c.pending[0][n:]
+			}
+
+			c.buffered -= n
+			resume := c.paused && c.buffered <= connLowWaterMark
+			if resume {
+				c.paused = false
+			}
+
+			ctx := c.ctx
+			c.mu.Unlock()
+
+			if resume {
+				setAutoRead(ctx, true)
+			}
+
+			return
+		}
+
+		if c.closed {
+			c.mu.Unlock()
+			return 0, io.EOF
+		}
+
+		deadline := c.readDeadline
+		c.mu.Unlock()
+
+		if deadline.IsZero() {
+			<-c.readableC
+			continue
+		}
+
+		d := time.Until(deadline)
+		if d <= 0 {
+			return 0, timeoutError{}
+		}
+
+		timer := time.NewTimer(d)
+
+		select {
+		case <-c.readableC:
+			timer.Stop()
+		case <-timer.C:
+			return 0, timeoutError{}
+		}
+	}
+}
+
+func (c *Conn) Write(b []byte) (n int, err error) {
+	c.mu.Lock()
+	ctx, closed, deadline := c.ctx, c.closed, c.writeDeadline
+	c.mu.Unlock()
+
+	if ctx == nil {
+		return 0, ErrConnNotAdded
+	}
+
+	if closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return 0, timeoutError{}
+	}
+
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	// copy b, the caller may reuse it once Write returns
+	buf := make([]byte, len(b))
+	copy(buf, b)
+
+	ctx.Write(buffer.NewByteBuf(buf, 0, len(buf)))
+	return len(b), nil
+}
+
+// Close closes the underlying channel.
+func (c *Conn) Close() error {
+	ctx := c.context()
+
+	c.shutdown()
+
+	if ctx != nil {
+		ctx.Pipeline().Channel().Close()
+	}
+
+	return nil
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	ctx := c.context()
+	if ctx == nil {
+		return nil
+	}
+
+	return ctx.Pipeline().Channel().LocalAddress()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	ctx := c.context()
+	if ctx == nil {
+		return nil
+	}
+
+	return ctx.Pipeline().Channel().RemoteAddress()
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.mu.Unlock()
+
+	// wake up a blocked Read to check the new deadline
+	c.notify()
+	return nil
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+
+	c.notify()
+	return nil
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.writeDeadline = t
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *Conn) context() *Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ctx
+}
+
+func (c *Conn) shutdown() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	c.notify()
+}
+
+// setAutoRead pauses or resumes reading of the channel, if it is FlowControlled.
+func setAutoRead(ctx *Context, autoRead bool) {
+	if ch, ok := ctx.Pipeline().Channel().(FlowControlled); ok {
+		ch.SetAutoRead(autoRead)
+	}
+}
+
+func (c *Conn) notify() {
+	select {
+	case c.readableC <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,129 @@
+package channel
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"ngio/buffer"
+	"testing"
+	"time"
+)
+
+func TestConn(t *testing.T) {
+	if _, err := NewConn().Write([]byte("x")); err != ErrConnNotAdded {
+		t.Fatalf("expected ErrConnNotAdded, got %v", err)
+	}
+
+	conn := NewConn()
+	ch := NewEmbeddedChannel(conn)
+
+	if n, err := conn.Write([]byte("ping")); n != 4 || err != nil {
+		t.Fatalf("unexpected write %d %v", n, err)
+	}
+
+	if bf, ok := ch.ReadOutbound().(buffer.ByteBuffer); !ok || string(bf.ReadBytes(bf.ReadableBytes())) != "ping" {
+		t.Fatal("expected the written bytes outbound")
+	}
+
+	ch.WriteInbound(buffer.NewByteBuf([]byte("pong"), 0, 4))
+
+	// read in parts
+	b := make([]byte, 3)
+	if n, err := conn.Read(b); n != 3 || err != nil || string(b) != "pon" {
+		t.Fatalf("unexpected read %q %v", b[:n], err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(-time.Second))
+	if n, err := conn.Read(b); n != 1 || err != nil || b[0] != 'g' {
+		t.Fatalf("expected the rest before the deadline applies, got %q %v", b[:n], err)
+	}
+
+	if _, err := conn.Read(b); err == nil || !err.(net.Error).Timeout() {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	// a blocked Read returns once bytes arrive
+	readC := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(b)
+		readC <- err
+	}()
+
+	ch.WriteInbound(buffer.NewByteBuf([]byte("!"), 0, 1))
+	if err := <-readC; err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ch.IsActive() {
+		t.Fatal("expected the channel to be closed")
+	}
+
+	if _, err := conn.Read(b); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	if _, err := conn.Write([]byte("x")); err != io.ErrClosedPipe {
+		t.Fatalf("expected ErrClosedPipe, got %v", err)
+	}
+}
+
+func TestConnBackpressure(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	conn := NewConn()
+	ch := NewTCPChannel(serverConn, 0, 0)
+	ch.Pipeline().AddLast("conn", conn)
+
+	go func() {
+		_ = ch.Serve()
+	}()
+	defer ch.Close()
+
+	sent := make([]byte, 2*connHighWaterMark)
+	for i := range sent {
+		sent[i] = byte(i)
+	}
+
+	writeC := make(chan error, 1)
+	go func() {
+		_, err := clientConn.Write(sent)
+		writeC <- err
+	}()
+
+	// nobody reads, the channel stops reading above the high water mark
+	deadline := time.Now().Add(time.Second)
+	for ch.IsAutoRead() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if ch.IsAutoRead() {
+		t.Fatal("expected the channel to stop reading")
+	}
+
+	// reading the pending bytes lets the channel read the rest
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	received := make([]byte, len(sent))
+	if _, err := io.ReadFull(conn, received); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(received, sent) {
+		t.Fatal("expected the bytes sent")
+	}
+
+	if err := <-writeC; err != nil {
+		t.Fatal(err)
+	}
+
+	if !ch.IsAutoRead() {
+		t.Fatal("expected the channel to read again")
+	}
+}
@@ -17,7 +17,7 @@ type Context struct {
 
 func NewContext(name string, handler interface{}, pipeline *Pipeline) *Context {
 	switch handler.(type) {
-	case ActiveHandler, InActiveHandler, ReadHandler, WriteHandler, ErrorHandler, *tailHandler:
+	case ActiveHandler, InActiveHandler, ReadHandler, WriteHandler, ErrorHandler, AddedHandler, RemovedHandler, UserEventHandler, *tailHandler:
 	default:
 		panic(fmt.Errorf(`invalid handler type. name: "%s"`, name))
 	}
@@ -101,6 +101,22 @@ func (ctx *Context) FireChannelErrorHandler(err error) {
 	next.handlerAdapter.HandleError(next, err)
 }
 
+// FireUserEventHandler passes evt to the next handler interested in user events.
+func (ctx *Context) FireUserEventHandler(evt interface{}) {
+	next := ctx.findInboundContext(UserEvent)
+
+	if next == nil {
+		// events are informative, nobody listening is no failure
+		ctx.log.Debugf("[%v] no user event handler after current context. event: %v", ctx, evt)
+		return
+	}
+
+	defer interceptError(ctx)
+
+	ctx.log.Debugf("[%v] => [%v] fire user event", ctx, next)
+	next.handlerAdapter.UserEventTriggered(next, evt)
+}
+
 func (ctx *Context) Next() *Context {
 	return ctx.next
 }
@@ -117,6 +133,11 @@ func (ctx *Context) Name() string {
 	return ctx.name
 }
 
+// Handler returns the handler of the context, as it was added to the pipeline.
+func (ctx *Context) Handler() interface{} {
+	return ctx.handlerAdapter.handler
+}
+
 func (ctx *Context) String() string {
 	buf := bytes.Buffer{}
 
@@ -0,0 +1,254 @@
+package channel
+
+import (
+	"bytes"
+	"net"
+	"ngio/logger"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var embeddedChannelId uint32
+
+type embeddedAddr struct{}
+
+func (embeddedAddr) Network() string {
+	return "embedded"
+}
+
+func (embeddedAddr) String() string {
+	return "embedded"
+}
+
+type embeddedTask struct {
+	deadline  time.Time
+	task      func()
+	cancelled bool
+}
+
+// EmbeddedChannel is a channel without any I/O underneath, used to unit test handlers and codecs.
+// Messages are fed to and collected from the pipeline synchronously, and scheduled tasks run
+// against a virtual clock which only moves by AdvanceTimeBy.
+type EmbeddedChannel struct {
+	id         uint32
+	isActive   bool
+	pipeline   *Pipeline
+	attributes Attributes
+	inbound    []interface{}
+	outbound   []interface{}
+	err        error
+	now        time.Time
+	tasks      []*embeddedTask
+	log        logger.Logger
+}
+
+// NewEmbeddedChannel creates an active EmbeddedChannel whose pipeline contains handlers, in order.
+// The handlers are named "handler0", "handler1" and so on.
+func NewEmbeddedChannel(handlers ...interface{}) *EmbeddedChannel {
+	ch := &EmbeddedChannel{
+		id:         atomic.AddUint32(&embeddedChannelId, 1),
+		isActive:   false,
+		attributes: NewDefaultAttributes(),
+		now:        time.Now(),
+		log:        logger.DefaultLogger(),
+	}
+
+	ch.pipeline = NewPipeline(ch)
+
+	// messages and errors reaching the tail are kept for ReadInbound and CheckError
+	ch.pipeline.tail.handlerAdapter = NewHandlerAdapter(ch.pipeline.tail.name, &embeddedTailHandler{ch: ch})
+
+	for i, handler := range handlers {
+		ch.pipeline.AddLast("handler"+strconv.Itoa(i), handler)
+	}
+
+	_ = ch.Serve()
+	return ch
+}
+
+func (ch *EmbeddedChannel) Id() uint32 {
+	return ch.id
+}
+
+func (ch *EmbeddedChannel) IsActive() bool {
+	return ch.isActive
+}
+
+func (ch *EmbeddedChannel) Pipeline() *Pipeline {
+	return ch.pipeline
+}
+
+func (ch *EmbeddedChannel) LocalAddress() net.Addr {
+	return embeddedAddr{}
+}
+
+func (ch *EmbeddedChannel) RemoteAddress() net.Addr {
+	return embeddedAddr{}
+}
+
+func (ch *EmbeddedChannel) Attributes() Attributes {
+	return ch.attributes
+}
+
+// Serve activates the channel and returns immediately. NewEmbeddedChannel already calls it.
+func (ch *EmbeddedChannel) Serve() error {
+	if ch.isActive {
+		return nil
+	}
+
+	ch.isActive = true
+	ch.pipeline.FireActiveHandler()
+	ch.RunPendingTasks()
+
+	return nil
+}
+
+// Write is invoked by the head of the pipeline, msg is kept for ReadOutbound.
+func (ch *EmbeddedChannel) Write(msg interface{}) {
+	if !ch.isActive {
+		return
+	}
+
+	ch.outbound = append(ch.outbound, msg)
+}
+
+func (ch *EmbeddedChannel) Close() {
+	if !ch.isActive {
+		return
+	}
+
+	ch.isActive = false
+	ch.pipeline.FireInActiveHandler()
+	ch.RunPendingTasks()
+}
+
+// WriteInbound fires msgs through the pipeline as if they were read from the network.
+// It reports whether any message reached the end of the pipeline.
+func (ch *EmbeddedChannel) WriteInbound(msgs ...interface{}) bool {
+	for _, msg := range msgs {
+		ch.pipeline.FireReadHandler(msg)
+	}
+
+	ch.RunPendingTasks()
+	return len(ch.inbound) > 0
+}
+
+// ReadInbound returns the oldest message which reached the end of the pipeline, or nil.
+func (ch *EmbeddedChannel) ReadInbound() interface{} {
+	if len(ch.inbound) == 0 {
+		return nil
+	}
+
+	msg := ch.inbound[0]
+	ch.inbound = ch.inbound[1:]
+	return msg
+}
+
+// WriteOutbound writes msgs from the tail of the pipeline.
+// It reports whether any message reached the head of the pipeline.
+func (ch *EmbeddedChannel) WriteOutbound(msgs ...interface{}) bool {
+	for _, msg := range msgs {
+		ch.pipeline.FireWriteHandler(msg)
+	}
+
+	ch.RunPendingTasks()
+	return len(ch.outbound) > 0
+}
+
+// ReadOutbound returns the oldest message which reached the head of the pipeline, or nil.
+func (ch *EmbeddedChannel) ReadOutbound() interface{} {
+	if len(ch.outbound) == 0 {
+		return nil
+	}
+
+	msg := ch.outbound[0]
+	ch.outbound = ch.outbound[1:]
+	return msg
+}
+
+// CheckError returns the first error which reached the end of the pipeline and was not
+// handled, and clears it.
+func (ch *EmbeddedChannel) CheckError() error {
+	err := ch.err
+	ch.err = nil
+	return err
+}
+
+// Finish closes the channel and reports whether any inbound or outbound message is left unread.
+func (ch *EmbeddedChannel) Finish() bool {
+	ch.Close()
+	return len(ch.inbound) > 0 || len(ch.outbound) > 0
+}
+
+// Now returns the virtual time of the channel.
+func (ch *EmbeddedChannel) Now() time.Time {
+	return ch.now
+}
+
+// Schedule queues task to run once the virtual clock reaches now + delay.
+// Tasks only run from RunPendingTasks or the methods calling it.
+func (ch *EmbeddedChannel) Schedule(delay time.Duration, task func()) (cancel func()) {
+	t := &embeddedTask{
+		deadline: ch.now.Add(delay),
+		task:     task,
+	}
+
+	ch.tasks = append(ch.tasks, t)
+
+	return func() {
+		t.cancelled = true
+	}
+}
+
+// AdvanceTimeBy moves the virtual clock forward and runs the tasks which became due.
+func (ch *EmbeddedChannel) AdvanceTimeBy(d time.Duration) {
+	ch.now = ch.now.Add(d)
+	ch.RunPendingTasks()
+}
+
+// RunPendingTasks runs every task whose deadline has passed, earliest first.
+func (ch *EmbeddedChannel) RunPendingTasks() {
+	for {
+		sort.SliceStable(ch.tasks, func(i, j int) bool {
+			return ch.tasks[i].deadline.Before(ch.tasks[j].deadline)
+		})
+
+		if len(ch.tasks) == 0 || ch.tasks[0].deadline.After(ch.now) {
+			return
+		}
+
+		t := ch.tasks[0]
+		ch.tasks = ch.tasks[1:]
+
+		if !t.cancelled {
+			t.task()
+		}
+	}
+}
+
+func (ch *EmbeddedChannel) String() string {
+	buf := bytes.Buffer{}
+
+	buf.WriteString("channel id: ")
+	buf.WriteString(strconv.FormatInt(int64(ch.id), 10))
+	buf.WriteString(", network: embedded, active: ")
+	buf.WriteString(strconv.FormatBool(ch.isActive))
+
+	return buf.String()
+}
+
+type embeddedTailHandler struct {
+	ch *EmbeddedChannel
+}
+
+func (handler *embeddedTailHandler) ChannelRead(ctx *Context, msg interface{}) {
+	handler.ch.inbound = append(handler.ch.inbound, msg)
+}
+
+func (handler *embeddedTailHandler) HandleError(ctx *Context, err error) {
+	if handler.ch.err == nil {
+		handler.ch.err = err
+	}
+}
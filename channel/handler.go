@@ -1,6 +1,9 @@
 package channel
 
-import "ngio/logger"
+import (
+	"ngio/buffer"
+	"ngio/logger"
+)
 
 type Flag int
 
@@ -11,6 +14,9 @@ const (
 	Read
 	Write
 	HandleError
+	Added
+	Removed
+	UserEvent
 )
 
 type ActiveHandler interface {
@@ -33,22 +39,50 @@ type ErrorHandler interface {
 	HandleError(ctx *Context, err error)
 }
 
+// AddedHandler is notified once its context is linked into a pipeline,
+// which may happen while the channel is already active.
+type AddedHandler interface {
+	HandlerAdded(ctx *Context)
+}
+
+// RemovedHandler is notified once its context is unlinked from a pipeline by Remove or Replace.
+type RemovedHandler interface {
+	HandlerRemoved(ctx *Context)
+}
+
+// UserEventHandler receives the events fired by other handlers, e.g. a completed TLS handshake.
+type UserEventHandler interface {
+	UserEventTriggered(ctx *Context, evt interface{})
+}
+
+// InboundBufferHolder is a handler holding inbound bytes it did not pass on yet, like a decoder
+// waiting for the rest of a frame. DrainInboundBuffer hands them over, e.g. to a handler added
+// in front of it which has to process them first, and returns nil if there are none.
+type InboundBufferHolder interface {
+	DrainInboundBuffer() buffer.ByteBuffer
+}
+
 type HandlerAdapter struct {
 	name            string
+	handler         interface{}
 	activeHandler   ActiveHandler
 	inActiveHandler InActiveHandler
 	readHandler     ReadHandler
 	writeHandler    WriteHandler
 	errorHandler    ErrorHandler
+	addedHandler    AddedHandler
+	removedHandler  RemovedHandler
+	eventHandler    UserEventHandler
 	flag            Flag
 	log             logger.Logger
 }
 
 func NewHandlerAdapter(name string, handler interface{}) *HandlerAdapter {
 	adapter := &HandlerAdapter{
-		name: name,
-		flag: None,
-		log:  logger.DefaultLogger(),
+		name:    name,
+		handler: handler,
+		flag:    None,
+		log:     logger.DefaultLogger(),
 	}
 
 	if h, ok := handler.(ActiveHandler); ok {
@@ -76,6 +110,21 @@ func NewHandlerAdapter(name string, handler interface{}) *HandlerAdapter {
 		adapter.flag |= HandleError
 	}
 
+	if h, ok := handler.(AddedHandler); ok {
+		adapter.addedHandler = h
+		adapter.flag |= Added
+	}
+
+	if h, ok := handler.(RemovedHandler); ok {
+		adapter.removedHandler = h
+		adapter.flag |= Removed
+	}
+
+	if h, ok := handler.(UserEventHandler); ok {
+		adapter.eventHandler = h
+		adapter.flag |= UserEvent
+	}
+
 	return adapter
 }
 
@@ -113,3 +162,24 @@ func (adapter *HandlerAdapter) HandleError(ctx *Context, err error) {
 		adapter.errorHandler.HandleError(ctx, err)
 	}
 }
+
+func (adapter *HandlerAdapter) HandlerAdded(ctx *Context) {
+	if adapter.addedHandler != nil {
+		adapter.log.Debugf("[handler: %s] invoke handler added", adapter.name)
+		adapter.addedHandler.HandlerAdded(ctx)
+	}
+}
+
+func (adapter *HandlerAdapter) HandlerRemoved(ctx *Context) {
+	if adapter.removedHandler != nil {
+		adapter.log.Debugf("[handler: %s] invoke handler removed", adapter.name)
+		adapter.removedHandler.HandlerRemoved(ctx)
+	}
+}
+
+func (adapter *HandlerAdapter) UserEventTriggered(ctx *Context, evt interface{}) {
+	if adapter.eventHandler != nil {
+		adapter.log.Debugf("[handler: %s] invoke user event triggered", adapter.name)
+		adapter.eventHandler.UserEventTriggered(ctx, evt)
+	}
+}
@@ -0,0 +1,250 @@
+package channel
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"ngio/logger"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrLocalAddrInUse        = errors.New("local: address already in use")
+	ErrLocalConnectRefused   = errors.New("local: connection refused")
+	ErrLocalAcceptorIsClosed = errors.New("local: acceptor is closed")
+)
+
+const localNetwork = "local"
+
+var (
+	localChannelId uint32
+	localEphemeral uint32
+	localAcceptors sync.Map // name -> *LocalAcceptor
+)
+
+// LocalAddr is the address of a LocalChannel, a plain name inside the current process.
+type LocalAddr struct {
+	name string
+}
+
+func NewLocalAddr(name string) *LocalAddr {
+	return &LocalAddr{name: name}
+}
+
+func newEphemeralLocalAddr() *LocalAddr {
+	return NewLocalAddr("ephemeral-" + strconv.FormatUint(uint64(atomic.AddUint32(&localEphemeral, 1)), 10))
+}
+
+func (addr *LocalAddr) Network() string {
+	return localNetwork
+}
+
+func (addr *LocalAddr) String() string {
+	return addr.name
+}
+
+// LocalAcceptor accepts in-process connections bound to a name, like a net.Listener does for sockets.
+type LocalAcceptor struct {
+	addr      *LocalAddr
+	acceptC   chan *LocalChannel
+	closeC    chan struct{}
+	closeOnce sync.Once
+}
+
+func ListenLocal(addr *LocalAddr) (*LocalAcceptor, error) {
+	acceptor := &LocalAcceptor{
+		addr:    addr,
+		acceptC: make(chan *LocalChannel),
+		closeC:  make(chan struct{}),
+	}
+
+	if _, loaded := localAcceptors.LoadOrStore(addr.name, acceptor); loaded {
+		return nil, ErrLocalAddrInUse
+	}
+
+	return acceptor, nil
+}
+
+func (acceptor *LocalAcceptor) Accept() (*LocalChannel, error) {
+	select {
+	case ch := <-acceptor.acceptC:
+		return ch, nil
+	case <-acceptor.closeC:
+		return nil, ErrLocalAcceptorIsClosed
+	}
+}
+
+func (acceptor *LocalAcceptor) Addr() net.Addr {
+	return acceptor.addr
+}
+
+func (acceptor *LocalAcceptor) Close() error {
+	acceptor.closeOnce.Do(func() {
+		localAcceptors.Delete(acceptor.addr.name)
+		close(acceptor.closeC)
+	})
+
+	return nil
+}
+
+// DialLocal connects to the acceptor bound to raddr and returns the client side of the connection.
+// The server side is handed to the acceptor.
+func DialLocal(raddr *LocalAddr) (*LocalChannel, error) {
+	v, ok := localAcceptors.Load(raddr.name)
+	if !ok {
+		return nil, ErrLocalConnectRefused
+	}
+
+	acceptor := v.(*LocalAcceptor)
+
+	laddr := newEphemeralLocalAddr()
+	client := newLocalChannel(laddr, raddr)
+	server := newLocalChannel(raddr, laddr)
+	client.peer, server.peer = server, client
+
+	select {
+	case acceptor.acceptC <- server:
+		return client, nil
+	case <-acceptor.closeC:
+		return nil, ErrLocalConnectRefused
+	}
+}
+
+// the states of a LocalChannel
+const (
+	localStateNew int32 = iota
+	localStateActive
+	localStateClosed
+)
+
+// LocalChannel is one side of an in-memory connection. Messages written to it are delivered
+// as they are to the peer's pipeline, without being copied or serialized.
+type LocalChannel struct {
+	id           uint32
+	state        int32
+	laddr, raddr *LocalAddr
+	peer         *LocalChannel
+	readC        chan interface{}
+	closeC       chan struct{}
+	quitC        chan error
+	pipeline     *Pipeline
+	attributes   Attributes
+	log          logger.Logger
+}
+
+func newLocalChannel(laddr, raddr *LocalAddr) *LocalChannel {
+	ch := &LocalChannel{
+		id:         atomic.AddUint32(&localChannelId, 1),
+		state:      localStateNew,
+		laddr:      laddr,
+		raddr:      raddr,
+		readC:      make(chan interface{}, 16),
+		closeC:     make(chan struct{}),
+		quitC:      make(chan error, 1),
+		attributes: NewDefaultAttributes(),
+		log:        logger.DefaultLogger(),
+	}
+
+	ch.pipeline = NewPipeline(ch)
+	return ch
+}
+
+func (ch *LocalChannel) Id() uint32 {
+	return ch.id
+}
+
+func (ch *LocalChannel) IsActive() bool {
+	return atomic.LoadInt32(&ch.state) == localStateActive
+}
+
+func (ch *LocalChannel) Pipeline() *Pipeline {
+	return ch.pipeline
+}
+
+func (ch *LocalChannel) LocalAddress() net.Addr {
+	return ch.laddr
+}
+
+func (ch *LocalChannel) RemoteAddress() net.Addr {
+	return ch.raddr
+}
+
+func (ch *LocalChannel) Attributes() Attributes {
+	return ch.attributes
+}
+
+func (ch *LocalChannel) Serve() error {
+	defer ch.log.Debugf("[%v] close", ch)
+
+	// closed before it was served
+	if !atomic.CompareAndSwapInt32(&ch.state, localStateNew, localStateActive) {
+		return <-ch.quitC
+	}
+
+	// messages the peer wrote before this side became active are queued in readC. The reads start
+	// first, handlers may wait in ChannelActive for a reply of the peer, e.g. a TLS handshake.
+	go ch.read()
+
+	ch.log.Debugf("[%v] serve", ch)
+
+	ch.pipeline.FireActiveHandler()
+
+	return <-ch.quitC
+}
+
+func (ch *LocalChannel) read() {
+	for {
+		select {
+		case <-ch.closeC:
+			return
+		case msg := <-ch.readC:
+			ch.pipeline.FireReadHandler(msg)
+		}
+	}
+}
+
+func (ch *LocalChannel) Write(msg interface{}) {
+	if !ch.IsActive() {
+		// todo: logger
+		return
+	}
+
+	select {
+	case ch.peer.readC <- msg:
+	case <-ch.peer.closeC:
+	}
+}
+
+func (ch *LocalChannel) Close() {
+	state := atomic.SwapInt32(&ch.state, localStateClosed)
+	if state == localStateClosed {
+		return
+	}
+
+	if state == localStateActive {
+		ch.pipeline.FireInActiveHandler()
+	}
+
+	close(ch.closeC)
+	ch.quitC <- nil
+
+	// closing one side always closes the other one
+	ch.peer.Close()
+}
+
+func (ch *LocalChannel) String() string {
+	buf := bytes.Buffer{}
+
+	buf.WriteString("channel id: ")
+	buf.WriteString(strconv.FormatInt(int64(ch.id), 10))
+	buf.WriteString(", network: ")
+	buf.WriteString(localNetwork)
+	buf.WriteString(", remote: ")
+	buf.WriteString(ch.raddr.String())
+	buf.WriteString(", active: ")
+	buf.WriteString(strconv.FormatBool(ch.IsActive()))
+
+	return buf.String()
+}
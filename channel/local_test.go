@@ -0,0 +1,161 @@
+package channel
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type recordHandler struct {
+	readC chan interface{}
+}
+
+func (h *recordHandler) ChannelRead(ctx *Context, msg interface{}) {
+	h.readC <- msg
+}
+
+type activeHandler struct {
+	activeC chan struct{}
+}
+
+func (h *activeHandler) ChannelActive(ctx *Context) {
+	close(h.activeC)
+	ctx.FireActiveHandler()
+}
+
+func TestLocalChannel(t *testing.T) {
+	acceptor, err := ListenLocal(NewLocalAddr("test-local-channel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer acceptor.Close()
+
+	if _, err := ListenLocal(NewLocalAddr("test-local-channel")); err != ErrLocalAddrInUse {
+		t.Fatalf("expected ErrLocalAddrInUse, got %v", err)
+	}
+
+	server := &recordHandler{readC: make(chan interface{}, 1)}
+
+	go func() {
+		ch, err := acceptor.Accept()
+		if err != nil {
+			return
+		}
+
+		ch.Pipeline().AddLast("record", server)
+		_ = ch.Serve()
+	}()
+
+	client, err := DialLocal(NewLocalAddr("test-local-channel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active := &activeHandler{activeC: make(chan struct{})}
+	client.Pipeline().AddLast("active", active)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- client.Serve()
+	}()
+
+	select {
+	case <-active.activeC:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for active")
+	}
+
+	client.Write("ping")
+
+	select {
+	case msg := <-server.readC:
+		if msg != "ping" {
+			t.Fatalf("expected ping, got %v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	client.Close()
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for close")
+	}
+
+	if _, err := DialLocal(NewLocalAddr("test-local-unbound")); err != ErrLocalConnectRefused {
+		t.Fatalf("expected ErrLocalConnectRefused, got %v", err)
+	}
+}
+
+// greetHandler waits in ChannelActive for the reply to its greeting, like a TLS client handshake.
+type greetHandler struct {
+	replyC chan interface{}
+	err    error
+}
+
+func (h *greetHandler) ChannelActive(ctx *Context) {
+	ctx.Write("hello")
+
+	select {
+	case <-h.replyC:
+	case <-time.After(time.Second):
+		h.err = errors.New("no reply in ChannelActive")
+	}
+}
+
+func (h *greetHandler) ChannelRead(ctx *Context, msg interface{}) {
+	h.replyC <- msg
+}
+
+type replyHandler struct{}
+
+func (replyHandler) ChannelRead(ctx *Context, msg interface{}) {
+	ctx.Write("reply")
+}
+
+func TestLocalChannelReplyInActive(t *testing.T) {
+	acceptor, err := ListenLocal(NewLocalAddr("test-local-reply"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer acceptor.Close()
+
+	go func() {
+		ch, err := acceptor.Accept()
+		if err != nil {
+			return
+		}
+
+		ch.Pipeline().AddLast("reply", replyHandler{})
+		_ = ch.Serve()
+	}()
+
+	client, err := DialLocal(NewLocalAddr("test-local-reply"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	greet := &greetHandler{replyC: make(chan interface{}, 1)}
+	client.Pipeline().AddLast("greet", greet)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- client.Serve()
+	}()
+
+	// closed from another goroutine while both sides are served
+	time.Sleep(20 * time.Millisecond)
+	client.peer.Close()
+
+	select {
+	case <-served:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for close")
+	}
+
+	if greet.err != nil {
+		t.Fatal(greet.err)
+	}
+}
@@ -0,0 +1,99 @@
+package channel
+
+import (
+	"net"
+	"ngio/option"
+	"syscall"
+	"unsafe"
+)
+
+// not exported by syscall on linux.
+const (
+	mcastJoinSourceGroup  = 0x2e
+	mcastLeaveSourceGroup = 0x2f
+)
+
+// sockaddrStorage is struct sockaddr_storage, aligned like the unsigned long it contains.
+// A trailing zero-size field would be padded, so the alignment field comes first.
+type sockaddrStorage struct {
+	_    [0]uintptr
+	data [128]byte
+}
+
+// groupSourceReq is struct group_source_req of MCAST_JOIN_SOURCE_GROUP and MCAST_LEAVE_SOURCE_GROUP.
+type groupSourceReq struct {
+	Interface uint32
+	Group     sockaddrStorage
+	Source    sockaddrStorage
+}
+
+func setMembership(c syscall.RawConn, ipv6, join bool, group net.IP, ifi *net.Interface) error {
+	index := 0
+	if ifi != nil {
+		index = ifi.Index
+	}
+
+	return option.Control(c, func(fd uintptr) error {
+		if !ipv6 {
+			mreq := &syscall.IPMreqn{Ifindex: int32(index)}
+			copy(mreq.Multiaddr[:], group.To4())
+
+			opt := syscall.IP_ADD_MEMBERSHIP
+			if !join {
+				opt = syscall.IP_DROP_MEMBERSHIP
+			}
+
+			return syscall.SetsockoptIPMreqn(int(fd), syscall.IPPROTO_IP, opt, mreq)
+		}
+
+		mreq := &syscall.IPv6Mreq{Interface: uint32(index)}
+		copy(mreq.Multiaddr[:], group.To16())
+
+		opt := syscall.IPV6_ADD_MEMBERSHIP
+		if !join {
+			opt = syscall.IPV6_DROP_MEMBERSHIP
+		}
+
+		return syscall.SetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IPV6, opt, mreq)
+	})
+}
+
+func setSourceMembership(c syscall.RawConn, ipv6, join bool, group, source net.IP, ifi *net.Interface) error {
+	req := &groupSourceReq{}
+	if ifi != nil {
+		req.Interface = uint32(ifi.Index)
+	}
+
+	level := syscall.IPPROTO_IP
+	if ipv6 {
+		level = syscall.IPPROTO_IPV6
+	}
+
+	putSockaddr(&req.Group, ipv6, group)
+	putSockaddr(&req.Source, ipv6, source)
+
+	opt := mcastJoinSourceGroup
+	if !join {
+		opt = mcastLeaveSourceGroup
+	}
+
+	// syscall has no setter for struct group_source_req, pass its raw bytes instead
+	b := (*[unsafe.Sizeof(groupSourceReq{})]byte)(unsafe.Pointer(req))
+
+	return option.Control(c, func(fd uintptr) error {
+		return syscall.SetsockoptString(int(fd), level, opt, string(b[:]))
+	})
+}
+
+func putSockaddr(ss *sockaddrStorage, ipv6 bool, ip net.IP) {
+	if !ipv6 {
+		sa := (*syscall.RawSockaddrInet4)(unsafe.Pointer(&ss.data[0]))
+		sa.Family = syscall.AF_INET
+		copy(sa.Addr[:], ip.To4())
+		return
+	}
+
+	sa := (*syscall.RawSockaddrInet6)(unsafe.Pointer(&ss.data[0]))
+	sa.Family = syscall.AF_INET6
+	copy(sa.Addr[:], ip.To16())
+}
@@ -0,0 +1,73 @@
+package channel
+
+import (
+	"net"
+	"ngio/buffer"
+	"ngio/option"
+	"testing"
+	"time"
+)
+
+func TestUDPChannelMulticast(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	group := net.IPv4(239, 255, 42, 99)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := NewUDPChannel(conn, &option.Options{})
+	received := &recordHandler{readC: make(chan interface{}, 1)}
+	ch.Pipeline().AddLast("record", received)
+
+	if err := ch.JoinGroup(net.IPv4(127, 0, 0, 1), lo); err != ErrNotMulticastAddress {
+		t.Fatalf("expected ErrNotMulticastAddress, got %v", err)
+	}
+
+	if err := ch.JoinGroup(group, lo); err != nil {
+		t.Skipf("multicast unavailable on loopback: %v", err)
+	}
+
+	go func() {
+		_ = ch.Serve()
+	}()
+	defer ch.Close()
+
+	sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	if err := option.SetupUDPOptions(sender, &option.Options{MulticastInterface: lo}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sender.WriteToUDP([]byte("hello"), &net.UDPAddr{IP: group, Port: conn.LocalAddr().(*net.UDPAddr).Port}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received.readC:
+		bf := msg.(*buffer.DatagramPacket).ByteBuf()
+		if string(bf.ReadBytes(bf.ReadableBytes())) != "hello" {
+			t.Fatal("unexpected datagram")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the group's datagram")
+	}
+
+	if err := ch.LeaveGroup(group, lo); err != nil {
+		t.Fatal(err)
+	}
+
+	// the membership is gone
+	if err := ch.LeaveGroup(group, lo); err == nil {
+		t.Fatal("expected leaving twice to fail")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package channel
+
+import (
+	"net"
+	"syscall"
+)
+
+func setMembership(c syscall.RawConn, ipv6, join bool, group net.IP, ifi *net.Interface) error {
+	return ErrMulticastUnsupported
+}
+
+func setSourceMembership(c syscall.RawConn, ipv6, join bool, group, source net.IP, ifi *net.Interface) error {
+	return ErrMulticastUnsupported
+}
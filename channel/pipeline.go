@@ -45,6 +45,8 @@ func (pipeline *Pipeline) AddFirst(name string, handler interface{}) {
 	pipeline.mu.Lock()
 	pipeline.insertBetween(added, pipeline.head, pipeline.head.next)
 	pipeline.mu.Unlock()
+
+	added.handlerAdapter.HandlerAdded(added)
 }
 
 func (pipeline *Pipeline) AddLast(name string, handler interface{}) {
@@ -53,68 +55,91 @@ func (pipeline *Pipeline) AddLast(name string, handler interface{}) {
 	pipeline.mu.Lock()
 	pipeline.insertBetween(added, pipeline.tail.prev, pipeline.tail)
 	pipeline.mu.Unlock()
+
+	added.handlerAdapter.HandlerAdded(added)
 }
 
 func (pipeline *Pipeline) AddAfter(basename, name string, handler interface{}) {
 	added := NewContext(name, handler, pipeline)
 
 	pipeline.mu.Lock()
-	defer pipeline.mu.Unlock()
 
 	if baseCtx, ok := pipeline.contexts[basename]; ok {
 		pipeline.insertBetween(added, baseCtx, baseCtx.next)
 	} else {
+		pipeline.mu.Unlock()
 		panic(fmt.Errorf(`non-existent context with name "%s"`, basename))
 	}
+
+	pipeline.mu.Unlock()
+
+	added.handlerAdapter.HandlerAdded(added)
 }
 
 func (pipeline *Pipeline) AddBefore(basename, name string, handler interface{}) {
 	added := NewContext(name, handler, pipeline)
 
 	pipeline.mu.Lock()
-	defer pipeline.mu.Unlock()
 
 	if base, ok := pipeline.contexts[basename]; ok {
 		pipeline.insertBetween(added, base.prev, base)
 	} else {
+		pipeline.mu.Unlock()
 		panic(fmt.Errorf(`non-existent context with name "%s"`, basename))
 	}
+
+	pipeline.mu.Unlock()
+
+	added.handlerAdapter.HandlerAdded(added)
 }
 
 func (pipeline *Pipeline) Remove(name string) {
 	pipeline.mu.Lock()
-	defer pipeline.mu.Unlock()
 
-	if deleted, ok := pipeline.contexts[name]; ok {
-		prev, next := deleted.prev, deleted.prev
-		deleted.prev.next = next
-		deleted.next.prev = prev
-		delete(pipeline.contexts, name)
-	} else {
+	deleted, ok := pipeline.contexts[name]
+	if !ok {
+		pipeline.mu.Unlock()
 		panic(fmt.Errorf(`non-existent context with name "%s"`, name))
 	}
+
+	// deleted keeps its own prev and next, so a handler removing itself can still fire events from its context.
+	prev, next := deleted.prev, deleted.next
+	prev.next = next
+	next.prev = prev
+	delete(pipeline.contexts, name)
+
+	pipeline.mu.Unlock()
+
+	deleted.handlerAdapter.HandlerRemoved(deleted)
 }
 
 func (pipeline *Pipeline) Replace(oldName, newName string, newHandler interface{}) {
 	replaced := NewContext(newName, newHandler, pipeline)
 
 	pipeline.mu.Lock()
-	defer pipeline.mu.Unlock()
 
-	if old, ok := pipeline.contexts[oldName]; ok {
-		if _, ok := pipeline.contexts[newName]; ok {
-			panic(fmt.Errorf(`repeated new name "%s"`, newName))
-		}
-
-		replaced.prev, replaced.next = old.prev, old.next
-		old.prev.next = replaced
-		old.next.prev = replaced
-
-		delete(pipeline.contexts, oldName)
-		pipeline.contexts[newName] = replaced
-	} else {
+	old, ok := pipeline.contexts[oldName]
+	if !ok {
+		pipeline.mu.Unlock()
 		panic(fmt.Errorf(`non-existent context with name "%s"`, oldName))
 	}
+
+	if _, ok := pipeline.contexts[newName]; ok && newName != oldName {
+		pipeline.mu.Unlock()
+		panic(fmt.Errorf(`repeated new name "%s"`, newName))
+	}
+
+	replaced.prev, replaced.next = old.prev, old.next
+	old.prev.next = replaced
+	old.next.prev = replaced
+
+	delete(pipeline.contexts, oldName)
+	pipeline.contexts[newName] = replaced
+
+	pipeline.mu.Unlock()
+
+	replaced.handlerAdapter.HandlerAdded(replaced)
+	old.handlerAdapter.HandlerRemoved(old)
 }
 
 func (pipeline *Pipeline) Channel() Channel {
@@ -141,6 +166,10 @@ func (pipeline *Pipeline) FireErrorHandler(err error) {
 	pipeline.head.FireChannelErrorHandler(err)
 }
 
+func (pipeline *Pipeline) FireUserEventHandler(evt interface{}) {
+	pipeline.head.FireUserEventHandler(evt)
+}
+
 type headHandler struct {
 }
 
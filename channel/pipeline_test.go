@@ -0,0 +1,40 @@
+package channel
+
+import "testing"
+
+type passHandler struct {
+	removed bool
+}
+
+func (h *passHandler) ChannelRead(ctx *Context, msg interface{}) {
+	ctx.FireReadHandler(msg)
+}
+
+func (h *passHandler) HandlerRemoved(ctx *Context) {
+	h.removed = true
+}
+
+func TestPipelineRemove(t *testing.T) {
+	first, middle, last := &passHandler{}, &passHandler{}, &passHandler{}
+	ch := NewEmbeddedChannel(first, middle, last)
+
+	ch.Pipeline().Remove("handler1")
+
+	if !middle.removed {
+		t.Fatal("expected the removed handler to be notified")
+	}
+
+	// the neighbours of the removed context are linked to each other
+	ch.WriteInbound("ping")
+	if msg := ch.ReadInbound(); msg != "ping" {
+		t.Fatalf("expected ping, got %v", msg)
+	}
+
+	ch.Pipeline().Remove("handler0")
+	ch.Pipeline().Remove("handler2")
+
+	ch.WriteInbound("pong")
+	if msg := ch.ReadInbound(); msg != "pong" {
+		t.Fatalf("expected pong, got %v", msg)
+	}
+}
@@ -0,0 +1,65 @@
+package channel
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// room for the larger of IP_PKTINFO and IPV6_PKTINFO control messages
+var packetInfoSize = syscall.CmsgSpace(syscall.SizeofInet6Pktinfo)
+
+// parsePacketInfo returns the destination address of a datagram from its control messages.
+func parsePacketInfo(oob []byte) net.IP {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil
+	}
+
+	for _, msg := range msgs {
+		if msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == syscall.IP_PKTINFO && len(msg.Data) >= syscall.SizeofInet4Pktinfo {
+			info := (*syscall.Inet4Pktinfo)(unsafe.Pointer(&msg.Data[0]))
+			return net.IPv4(info.Addr[0], info.Addr[1], info.Addr[2], info.Addr[3])
+		}
+
+		if msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == syscall.IPV6_PKTINFO && len(msg.Data) >= syscall.SizeofInet6Pktinfo {
+			info := (*syscall.Inet6Pktinfo)(unsafe.Pointer(&msg.Data[0]))
+			ip := make(net.IP, net.IPv6len)
+			copy(ip, info.Addr[:])
+			return ip
+		}
+	}
+
+	return nil
+}
+
+// marshalPacketInfo returns the control message sending a datagram from ip.
+// ipv6 is the family of the socket, IPv4 addresses are sent as mapped ones on IPv6 sockets.
+func marshalPacketInfo(ip net.IP, ipv6 bool) []byte {
+	if !ipv6 {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return nil
+		}
+
+		b := make([]byte, syscall.CmsgSpace(syscall.SizeofInet4Pktinfo))
+		h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[0]))
+		h.Level = syscall.IPPROTO_IP
+		h.Type = syscall.IP_PKTINFO
+		h.SetLen(syscall.CmsgLen(syscall.SizeofInet4Pktinfo))
+
+		info := (*syscall.Inet4Pktinfo)(unsafe.Pointer(&b[syscall.CmsgLen(0)]))
+		copy(info.Spec_dst[:], ip4)
+		return b
+	}
+
+	b := make([]byte, syscall.CmsgSpace(syscall.SizeofInet6Pktinfo))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = syscall.IPPROTO_IPV6
+	h.Type = syscall.IPV6_PKTINFO
+	h.SetLen(syscall.CmsgLen(syscall.SizeofInet6Pktinfo))
+
+	info := (*syscall.Inet6Pktinfo)(unsafe.Pointer(&b[syscall.CmsgLen(0)]))
+	copy(info.Addr[:], ip.To16())
+	return b
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package channel
+
+import "net"
+
+var packetInfoSize = 0
+
+func parsePacketInfo(oob []byte) net.IP {
+	return nil
+}
+
+func marshalPacketInfo(ip net.IP, ipv6 bool) []byte {
+	return nil
+}
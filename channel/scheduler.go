@@ -0,0 +1,34 @@
+package channel
+
+import "time"
+
+// Scheduler runs tasks after a delay. A channel implementing Scheduler provides its own clock,
+// like EmbeddedChannel does, otherwise handlers fall back to the wall clock, see SchedulerOf.
+type Scheduler interface {
+	Now() time.Time
+	Schedule(delay time.Duration, task func()) (cancel func())
+}
+
+var defaultScheduler Scheduler = timeScheduler{}
+
+// SchedulerOf returns the scheduler timers of handlers in ch's pipeline should use.
+func SchedulerOf(ch Channel) Scheduler {
+	if s, ok := ch.(Scheduler); ok {
+		return s
+	}
+
+	return defaultScheduler
+}
+
+type timeScheduler struct{}
+
+func (timeScheduler) Now() time.Time {
+	return time.Now()
+}
+
+func (timeScheduler) Schedule(delay time.Duration, task func()) (cancel func()) {
+	t := time.AfterFunc(delay, task)
+	return func() {
+		t.Stop()
+	}
+}
@@ -2,6 +2,7 @@ package channel
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net"
 	"ngio/buffer"
@@ -12,31 +13,63 @@ import (
 	"time"
 )
 
+var (
+	ErrReadTimeout = errors.New("tcp: nothing read within the read deadline period")
+)
+
+// how long Close waits for the queued writes to be flushed
+const closeFlushTimeout = time.Second
+
+// the default water marks of the bytes queued for writing
+const (
+	defaultLowWaterMark  = 32 * 1024
+	defaultHighWaterMark = 64 * 1024
+)
+
+// the states of a TCPChannel
+const (
+	tcpStateNew int32 = iota
+	tcpStateActive
+	tcpStateClosed
+)
+
 var tcpChannelId uint32
 
 // TCPChannel is a connection between server and client
 type TCPChannel struct {
+	pendingBytes        int64
 	id                  uint32
-	isActive            bool
+	state               int32
 	conn                net.Conn
 	closeC              chan struct{}
+	flushedC            chan struct{}
 	quitC               chan error
 	writeC              chan buffer.ByteBuffer
+	writeMu             sync.RWMutex // held by senders on writeC, exclusively to close it
+	writeClosed         bool
 	wg                  sync.WaitGroup
 	pipeline            *Pipeline
 	recvAllocator       *buffer.RecvByteBufAllocator
 	attributes          Attributes
 	writeDeadlinePeriod time.Duration
 	readDeadlinePeriod  time.Duration
+	autoRead            int32
+	resumeC             chan struct{}
+	writable            int32
+	lowWaterMark        int64
+	highWaterMark       int64
+	allowHalfClosure    int32
+	inputShutdown       int32
+	outputShutdown      int32
 	log                 logger.Logger
 }
 
 func NewTCPChannel(conn net.Conn, writeDeadlinePeriod, readDeadlinePeriod time.Duration) *TCPChannel {
 	ch := &TCPChannel{
 		id:                  atomic.AddUint32(&tcpChannelId, 1),
-		isActive:            false,
 		conn:                conn,
 		closeC:              make(chan struct{}),
+		flushedC:            make(chan struct{}),
 		quitC:               make(chan error, 1),
 		writeC:              make(chan buffer.ByteBuffer, 16),
 		wg:                  sync.WaitGroup{},
@@ -44,6 +77,11 @@ func NewTCPChannel(conn net.Conn, writeDeadlinePeriod, readDeadlinePeriod time.D
 		attributes:          NewDefaultAttributes(),
 		writeDeadlinePeriod: writeDeadlinePeriod,
 		readDeadlinePeriod:  readDeadlinePeriod,
+		autoRead:            1,
+		resumeC:             make(chan struct{}, 1),
+		writable:            1,
+		lowWaterMark:        defaultLowWaterMark,
+		highWaterMark:       defaultHighWaterMark,
 		log:                 logger.DefaultLogger(),
 	}
 
@@ -56,7 +94,7 @@ func (ch *TCPChannel) Id() uint32 {
 }
 
 func (ch *TCPChannel) IsActive() bool {
-	return ch.isActive
+	return atomic.LoadInt32(&ch.state) == tcpStateActive
 }
 
 func (ch *TCPChannel) Pipeline() *Pipeline {
@@ -92,10 +130,12 @@ func (ch *TCPChannel) Serve() (err error) {
 		}
 	}()
 
+	// added before Close may wait for them
+	ch.wg.Add(2)
 	go ch.read()
 	go ch.write()
 
-	ch.isActive = true
+	atomic.StoreInt32(&ch.state, tcpStateActive)
 
 	ch.log.Debugf("[%v] serve", ch)
 
@@ -105,7 +145,6 @@ func (ch *TCPChannel) Serve() (err error) {
 }
 
 func (ch *TCPChannel) read() {
-	ch.wg.Add(1)
 	defer ch.wg.Done()
 
 	for {
@@ -113,6 +152,16 @@ func (ch *TCPChannel) read() {
 		case <-ch.closeC:
 			return
 		default:
+			// paused by SetAutoRead(false)
+			if atomic.LoadInt32(&ch.autoRead) == 0 {
+				select {
+				case <-ch.resumeC:
+					continue
+				case <-ch.closeC:
+					return
+				}
+			}
+
 			buf := ch.recvAllocator.Allocate()
 
 			// set read timeout
@@ -132,18 +181,36 @@ func (ch *TCPChannel) read() {
 				continue
 			}
 
-			if err == io.EOF || err == io.ErrUnexpectedEOF {
-				ch.Close()
+			if err == io.EOF && atomic.LoadInt32(&ch.allowHalfClosure) == 1 {
+				ch.shutdownInput()
+				return
 			}
 
+			// the read deadline passed, see timeout.IdleStateHandler for heartbeats instead
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() && ch.IsActive() {
+				ch.pipeline.FireErrorHandler(ErrReadTimeout)
+			}
+
+			ch.Close()
 			return
 		}
 	}
 }
 
+// shutdownInput tells the pipeline the peer will not send anymore, the channel is closed once
+// its output is shut down too.
+func (ch *TCPChannel) shutdownInput() {
+	atomic.StoreInt32(&ch.inputShutdown, 1)
+	ch.pipeline.FireUserEventHandler(ChannelInputShutdownEvent{})
+
+	if atomic.LoadInt32(&ch.outputShutdown) == 1 {
+		ch.Close()
+	}
+}
+
 func (ch *TCPChannel) write() {
-	ch.wg.Add(1)
 	defer ch.wg.Done()
+	defer close(ch.flushedC)
 
 	for {
 		select {
@@ -151,7 +218,14 @@ func (ch *TCPChannel) write() {
 			return
 		default:
 			for buf := range ch.writeC {
-				unwritten := int64(buf.ReadableBytes())
+				// queued by CloseWrite, after everything written before
+				if buf == nil {
+					ch.shutdownOutput()
+					continue
+				}
+
+				size := int64(buf.ReadableBytes())
+				unwritten := size
 
 				for unwritten > 0 {
 
@@ -176,35 +250,150 @@ func (ch *TCPChannel) write() {
 
 					return
 				}
+
+				ch.decrementPendingBytes(size)
 			}
 		}
 	}
 }
 
+// Write queues msg for the write loop. Closing, the handlers may still write while they are told
+// by ChannelInActive, e.g. a TLS close_notify, which is flushed before the connection is closed.
 func (ch *TCPChannel) Write(msg interface{}) {
-	if !ch.isActive {
+	if atomic.LoadInt32(&ch.state) == tcpStateNew {
 		// todo: logger
 		return
 	}
 
 	if buf, ok := msg.(buffer.ByteBuffer); ok {
-		ch.writeC <- buf
+		ch.incrementPendingBytes(int64(buf.ReadableBytes()))
+		ch.enqueue(buf)
 	}
 	// todo: handle msg isn't ByteBuffer
 }
 
+// enqueue passes buf to the write loop unless the channel is closed, a nil buf shuts down the output.
+func (ch *TCPChannel) enqueue(buf buffer.ByteBuffer) {
+	ch.writeMu.RLock()
+	defer ch.writeMu.RUnlock()
+
+	if ch.writeClosed {
+		return
+	}
+
+	select {
+	case ch.writeC <- buf:
+	case <-ch.closeC:
+	}
+}
+
+// SetAutoRead pauses or resumes reading, the read in progress still completes when pausing.
+func (ch *TCPChannel) SetAutoRead(autoRead bool) {
+	if !autoRead {
+		atomic.StoreInt32(&ch.autoRead, 0)
+		return
+	}
+
+	if atomic.CompareAndSwapInt32(&ch.autoRead, 0, 1) {
+		select {
+		case ch.resumeC <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (ch *TCPChannel) IsAutoRead() bool {
+	return atomic.LoadInt32(&ch.autoRead) == 1
+}
+
+// IsWritable reports whether the bytes queued for writing stayed below the high water mark,
+// or fell below the low one since.
+func (ch *TCPChannel) IsWritable() bool {
+	return atomic.LoadInt32(&ch.writable) == 1
+}
+
+// SetWriteBufferWaterMark sets the water marks of the bytes queued for writing, 32 KiB and 64 KiB by default.
+func (ch *TCPChannel) SetWriteBufferWaterMark(low, high int) {
+	atomic.StoreInt64(&ch.lowWaterMark, int64(low))
+	atomic.StoreInt64(&ch.highWaterMark, int64(high))
+}
+
+// PendingBytes returns how many bytes are queued for writing.
+func (ch *TCPChannel) PendingBytes() int64 {
+	return atomic.LoadInt64(&ch.pendingBytes)
+}
+
+func (ch *TCPChannel) incrementPendingBytes(n int64) {
+	pending := atomic.AddInt64(&ch.pendingBytes, n)
+	if pending > atomic.LoadInt64(&ch.highWaterMark) && atomic.CompareAndSwapInt32(&ch.writable, 1, 0) {
+		ch.pipeline.FireUserEventHandler(WritabilityChangedEvent{Writable: false})
+	}
+}
+
+func (ch *TCPChannel) decrementPendingBytes(n int64) {
+	pending := atomic.AddInt64(&ch.pendingBytes, -n)
+	if pending < atomic.LoadInt64(&ch.lowWaterMark) && atomic.CompareAndSwapInt32(&ch.writable, 0, 1) {
+		ch.pipeline.FireUserEventHandler(WritabilityChangedEvent{Writable: true})
+	}
+}
+
+// SetAllowHalfClosure keeps the channel open once the peer shut down its output, so it can still be
+// written to. The pipeline gets a ChannelInputShutdownEvent instead, see CloseWrite.
+func (ch *TCPChannel) SetAllowHalfClosure(allow bool) {
+	if allow {
+		atomic.StoreInt32(&ch.allowHalfClosure, 1)
+	} else {
+		atomic.StoreInt32(&ch.allowHalfClosure, 0)
+	}
+}
+
+// CloseWrite shuts down the output once the bytes written before are sent, the peer reads EOF.
+// The channel is closed once its input is shut down too.
+func (ch *TCPChannel) CloseWrite() {
+	if !ch.IsActive() {
+		return
+	}
+
+	ch.enqueue(nil)
+}
+
+func (ch *TCPChannel) shutdownOutput() {
+	if !atomic.CompareAndSwapInt32(&ch.outputShutdown, 0, 1) {
+		return
+	}
+
+	if conn, ok := ch.conn.(interface{ CloseWrite() error }); ok {
+		if err := conn.CloseWrite(); err != nil {
+			ch.log.Errorf("[%v] shutdown output\r\n %v", ch, err)
+		}
+	}
+
+	if atomic.LoadInt32(&ch.inputShutdown) == 1 {
+		go ch.Close()
+	}
+}
+
 func (ch *TCPChannel) Close() {
-	if !ch.isActive {
+	// the read and write loops, the handlers and e.g. the peer of a relay may close concurrently
+	if !atomic.CompareAndSwapInt32(&ch.state, tcpStateActive, tcpStateClosed) {
 		return
 	}
 
-	ch.isActive = false
 	ch.pipeline.FireInActiveHandler()
 
 	go func() {
 		// broadcast close signal
 		close(ch.closeC)
+
+		// the senders blocked on a full writeC gave up on closeC
+		ch.writeMu.Lock()
+		ch.writeClosed = true
 		close(ch.writeC)
+		ch.writeMu.Unlock()
+
+		// let the write loop flush what is queued, e.g. a TLS close_notify, but not for long
+		_ = ch.conn.SetWriteDeadline(time.Now().Add(closeFlushTimeout))
+		<-ch.flushedC
 
 		err := ch.conn.Close()
 
@@ -224,7 +413,7 @@ func (ch *TCPChannel) String() string {
 	buf.WriteString(", remote: ")
 	buf.WriteString(ch.RemoteAddress().String())
 	buf.WriteString(", active: ")
-	buf.WriteString(strconv.FormatBool(ch.isActive))
+	buf.WriteString(strconv.FormatBool(ch.IsActive()))
 
 	return buf.String()
 }
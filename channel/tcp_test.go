@@ -0,0 +1,64 @@
+package channel
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"ngio/buffer"
+	"testing"
+	"time"
+)
+
+func TestTCPChannelWriteWhileClosing(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		client, server := net.Pipe()
+		go func() {
+			_, _ = io.Copy(ioutil.Discard, client)
+		}()
+
+		ch := NewTCPChannel(server, 0, 0)
+		active := &activeHandler{activeC: make(chan struct{})}
+		ch.Pipeline().AddLast("active", active)
+
+		served := make(chan error, 1)
+		go func() {
+			served <- ch.Serve()
+		}()
+
+		select {
+		case <-active.activeC:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for active")
+		}
+
+		// writes and half closes racing with Close must neither panic nor block
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			for j := 0; j < 64; j++ {
+				ch.Write(buffer.NewByteBuf([]byte("ping"), 0, 4))
+				ch.CloseWrite()
+			}
+		}()
+
+		ch.Close()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for writes")
+		}
+
+		ch.Write(buffer.NewByteBuf([]byte("ping"), 0, 4))
+		ch.CloseWrite()
+
+		select {
+		case <-served:
+		case <-time.After(2 * closeFlushTimeout):
+			t.Fatal("timeout waiting for close")
+		}
+
+		client.Close()
+	}
+}
@@ -2,31 +2,63 @@ package channel
 
 import (
 	"bytes"
+	"errors"
 	"net"
 	"ngio/buffer"
 	"ngio/logger"
+	"ngio/option"
 	"strconv"
 	"sync/atomic"
+	"syscall"
 )
 
+var (
+	ErrNotMulticastAddress  = errors.New("udp: not a multicast address")
+	ErrMulticastUnsupported = errors.New("udp: multicast membership unsupported on this platform")
+)
+
+// the largest datagram fits, though IPv4 payloads are at most 65507 bytes
+const defaultMaxDatagramSize = 65535
+
 var udpChannelId uint32
 
 type UDPChannel struct {
+	dropped    uint64 // first for 64-bit alignment of atomic operations on 32-bit platforms
 	id         uint32
 	isActive   bool
 	conn       *net.UDPConn
+	opts       *option.Options
+	ipv6       bool
+	raddr      *net.UDPAddr
 	pipeline   *Pipeline
 	attributes Attributes
+	readBuf    []byte
+	readOOB    []byte
+	alloc      *buffer.DatagramAllocator
+	batch      *udpBatchIO
+	writeC     chan *buffer.DatagramPacket
+	closeC     chan struct{}
 	quitC      chan error
 	log        logger.Logger
 }
 
-func NewUDPChannel(conn *net.UDPConn) *UDPChannel {
+func NewUDPChannel(conn *net.UDPConn, opts *option.Options) *UDPChannel {
+	if opts == nil {
+		opts = new(option.Options)
+	}
+
+	laddr, _ := conn.LocalAddr().(*net.UDPAddr)
+	raddr, _ := conn.RemoteAddr().(*net.UDPAddr)
+
 	ch := &UDPChannel{
 		id:         atomic.AddUint32(&udpChannelId, 1),
 		isActive:   false,
 		conn:       conn,
+		opts:       opts,
+		ipv6:       laddr != nil && laddr.IP.To4() == nil,
+		raddr:      raddr,
 		attributes: NewDefaultAttributes(),
+		closeC:     make(chan struct{}),
 		quitC:      make(chan error, 1),
 		log:        logger.DefaultLogger(),
 	}
@@ -55,14 +87,24 @@ func (ch *UDPChannel) LocalAddress() net.Addr {
 	return ch.conn.LocalAddr()
 }
 
+// RemoteAddress returns the connected peer, nil unless the socket is connected.
 func (ch *UDPChannel) RemoteAddress() net.Addr {
-	panic("not support")
+	if ch.raddr == nil {
+		return nil
+	}
+
+	return ch.raddr
 }
 
 func (ch *UDPChannel) Attributes() Attributes {
 	return ch.attributes
 }
 
+// Dropped returns the number of datagrams dropped because the dispatch was full, see option.UDPOverflow.
+func (ch *UDPChannel) Dropped() uint64 {
+	return atomic.LoadUint64(&ch.dropped)
+}
+
 func (ch *UDPChannel) Serve() (err error) {
 	defer func() {
 		if err != nil {
@@ -72,51 +114,263 @@ func (ch *UDPChannel) Serve() (err error) {
 		}
 	}()
 
+	oobSize := 0
+	if ch.opts.UDPPacketInfo {
+		oobSize = packetInfoSize
+	}
+
+	ch.alloc = buffer.NewDatagramAllocator(buffer.DefaultMaximum)
+	ch.batch = newUDPBatchIO(ch.conn, ch.opts.UDPBatchSize, ch.maxDatagramSize(), oobSize, ch.ipv6)
+	if ch.batch != nil {
+		// writes are queued and flushed together by the write loop
+		ch.writeC = make(chan *buffer.DatagramPacket, ch.opts.UDPBatchSize)
+		go ch.writeLoop()
+	} else {
+		ch.readBuf = make([]byte, ch.maxDatagramSize())
+		ch.readOOB = make([]byte, oobSize)
+	}
+
 	ch.isActive = true
 	ch.log.Debugf("[%v] serve", ch)
 
+	dispatcher := newUDPDispatcher(ch.pipeline, &ch.dropped, ch.opts)
+	defer dispatcher.close()
+
 	for {
 		select {
 		case exitErr := <-ch.quitC:
 			return exitErr
 		default:
-			// todo: size by config
-			buf := make([]byte, 1024)
+			if ch.batch != nil {
+				n, err := ch.batch.read()
+				if err != nil {
+					if ch.readError(err) {
+						continue
+					}
+
+					return ch.stop(err)
+				}
+
+				port := ch.conn.LocalAddr().(*net.UDPAddr).Port
+				for i := 0; i < n; i++ {
+					dispatcher.dispatch(ch.batch.packet(i, port, ch.alloc))
+				}
+				continue
+			}
 
-			r, raddr, err := ch.conn.ReadFromUDP(buf)
+			packet, err := ch.read(ch.readBuf)
 			if err != nil {
-				ch.Close()
-				return err
+				if ch.readError(err) {
+					continue
+				}
+
+				return ch.stop(err)
 			}
 
-			bf := buffer.NewByteBuf(buf, 0, r)
-			packet := buffer.NewDatagramPacket(raddr, bf)
+			dispatcher.dispatch(packet)
+		}
+	}
+}
+
+// readError reports whether the serve loop goes on after err. A connected peer refusing an earlier
+// datagram is passed to the pipeline, the socket itself is still usable.
+func (ch *UDPChannel) readError(err error) bool {
+	if ch.raddr != nil && isConnRefused(err) {
+		ch.pipeline.FireErrorHandler(err)
+		return true
+	}
+
+	return false
+}
+
+// stop ends the serve loop after the read error err, which is expected if Close closed the socket.
+func (ch *UDPChannel) stop(err error) error {
+	select {
+	case <-ch.closeC:
+		return <-ch.quitC
+	default:
+	}
+
+	ch.Close()
+	return err
+}
+
+func (ch *UDPChannel) maxDatagramSize() int {
+	if ch.opts.UDPMaxDatagramSize > 0 {
+		return ch.opts.UDPMaxDatagramSize
+	}
 
-			go ch.pipeline.FireReadHandler(packet)
+	return defaultMaxDatagramSize
+}
+
+// read reads one datagram into buf, which is reused, the returned packet holds a copy of its bytes
+// taken from ch.alloc.
+func (ch *UDPChannel) read(buf []byte) (*buffer.DatagramPacket, error) {
+	if !ch.opts.UDPPacketInfo {
+		r, raddr, err := ch.conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil, err
 		}
+
+		return buffer.NewDatagramPacket(raddr, ch.alloc.Allocate(buf[:r])), nil
+	}
+
+	oob := ch.readOOB
+
+	r, oobn, _, raddr, err := ch.conn.ReadMsgUDP(buf, oob)
+	if err != nil {
+		return nil, err
+	}
+
+	// the local address the datagram arrived on, which may differ from the bound one, e.g. 0.0.0.0
+	var laddr *net.UDPAddr
+	if ip := parsePacketInfo(oob[:oobn]); ip != nil {
+		laddr = &net.UDPAddr{IP: ip, Port: ch.conn.LocalAddr().(*net.UDPAddr).Port}
 	}
+
+	return buffer.NewAddressedDatagramPacket(laddr, raddr, ch.alloc.Allocate(buf[:r])), nil
 }
 
+// Write sends a buffer.DatagramPacket to its remote address. On a connected socket, e.g. one of
+// dialer.UDPDialer, it also takes a buffer.ByteBuffer, and every datagram goes to the connected peer.
 func (ch *UDPChannel) Write(msg interface{}) {
 	if !ch.isActive {
 		// todo: logger
 		return
 	}
 
-	if packet, ok := msg.(*buffer.DatagramPacket); ok {
-		shouldWrite := packet.ByteBuf().ReadableBytes()
+	var packet *buffer.DatagramPacket
 
-		w, err := ch.conn.WriteToUDP(packet.ByteBuf().ReadBytes(shouldWrite), packet.RemoteAddress())
-		if err != nil {
+	switch m := msg.(type) {
+	case *buffer.DatagramPacket:
+		packet = m
+	case buffer.ByteBuffer:
+		if ch.raddr == nil {
 			// todo: logger
+			return
 		}
+		packet = buffer.NewDatagramPacket(nil, m)
+	default:
+		return
+	}
 
-		if w != shouldWrite {
-			// todo: logger
+	if ch.raddr != nil {
+		// the destination of a connected socket is fixed, sending to an address fails
+		packet = buffer.NewAddressedDatagramPacket(packet.LocalAddress(), nil, packet.ByteBuf())
+	}
+
+	if ch.writeC != nil {
+		select {
+		case ch.writeC <- packet:
+		case <-ch.closeC:
+		}
+		return
+	}
+
+	shouldWrite := packet.ByteBuf().ReadableBytes()
+
+	var w int
+	var err error
+
+	// reply from the local address the request arrived on
+	if laddr := packet.LocalAddress(); ch.opts.UDPPacketInfo && laddr != nil && !laddr.IP.IsUnspecified() {
+		w, _, err = ch.conn.WriteMsgUDP(packet.ByteBuf().ReadBytes(shouldWrite), marshalPacketInfo(laddr.IP, ch.ipv6), packet.RemoteAddress())
+	} else if ch.raddr != nil {
+		w, err = ch.conn.Write(packet.ByteBuf().ReadBytes(shouldWrite))
+	} else {
+		w, err = ch.conn.WriteToUDP(packet.ByteBuf().ReadBytes(shouldWrite), packet.RemoteAddress())
+	}
+
+	if err != nil {
+		ch.writeError(err)
+		return
+	}
+
+	if w != shouldWrite {
+		// todo: logger
+	}
+}
+
+// writeError surfaces the refusals of a connected peer, an ICMP port unreachable answering an earlier datagram.
+func (ch *UDPChannel) writeError(err error) {
+	if ch.raddr != nil && isConnRefused(err) {
+		ch.pipeline.FireErrorHandler(err)
+		return
+	}
+
+	// todo: logger
+}
+
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// writeLoop sends the queued packets, all the ones queued while the previous batch was sent at once.
+func (ch *UDPChannel) writeLoop() {
+	packets := make([]*buffer.DatagramPacket, 0, cap(ch.writeC))
+
+	for {
+		select {
+		case <-ch.closeC:
+			return
+		case packet := <-ch.writeC:
+			packets = append(packets[:0], packet)
+
+		drain:
+			for len(packets) < cap(packets) {
+				select {
+				case packet := <-ch.writeC:
+					packets = append(packets, packet)
+				default:
+					break drain
+				}
+			}
+
+			if err := ch.batch.write(packets, ch.writeError); err != nil {
+				ch.writeError(err)
+			}
 		}
 	}
 }
 
+// JoinGroup joins the multicast group on ifi, or on the interface the kernel chooses if ifi is nil.
+func (ch *UDPChannel) JoinGroup(group net.IP, ifi *net.Interface) error {
+	return ch.setMembership(true, group, nil, ifi)
+}
+
+func (ch *UDPChannel) LeaveGroup(group net.IP, ifi *net.Interface) error {
+	return ch.setMembership(false, group, nil, ifi)
+}
+
+// JoinSourceSpecificGroup joins the multicast group on ifi, only receiving the datagrams sent by source.
+func (ch *UDPChannel) JoinSourceSpecificGroup(group, source net.IP, ifi *net.Interface) error {
+	return ch.setMembership(true, group, source, ifi)
+}
+
+func (ch *UDPChannel) LeaveSourceSpecificGroup(group, source net.IP, ifi *net.Interface) error {
+	return ch.setMembership(false, group, source, ifi)
+}
+
+func (ch *UDPChannel) setMembership(join bool, group, source net.IP, ifi *net.Interface) error {
+	if !group.IsMulticast() {
+		return ErrNotMulticastAddress
+	}
+
+	raw, err := ch.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	// the family of the group decides, IPv4 groups may be joined on dual-stack IPv6 sockets
+	ipv6 := group.To4() == nil
+
+	if source == nil {
+		return setMembership(raw, ipv6, join, group, ifi)
+	}
+
+	return setSourceMembership(raw, ipv6, join, group, source, ifi)
+}
+
 func (ch *UDPChannel) Close() {
 	if !ch.isActive {
 		return
@@ -124,11 +378,12 @@ func (ch *UDPChannel) Close() {
 
 	ch.isActive = false
 
-	ch.log.Infof("[network: %v, local: %v, remote: %v] stop listening", ch.RemoteAddress().Network(), ch.LocalAddress().Network(), ch.RemoteAddress())
+	ch.log.Infof("[network: %v, local: %v] stop listening", ch.LocalAddress().Network(), ch.LocalAddress())
 
+	close(ch.closeC)
 	ch.quitC <- ch.conn.Close()
 
-	ch.log.Infof("[network: %v, local: %v, remote %v] listen stopped", ch.RemoteAddress().Network(), ch.LocalAddress().Network(), ch.RemoteAddress())
+	ch.log.Infof("[network: %v, local: %v] listen stopped", ch.LocalAddress().Network(), ch.LocalAddress())
 
 }
 
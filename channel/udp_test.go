@@ -0,0 +1,96 @@
+package channel
+
+import (
+	"net"
+	"ngio/buffer"
+	"ngio/option"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type errorRecordHandler struct {
+	errC chan error
+}
+
+func (h *errorRecordHandler) HandleError(ctx *Context, err error) {
+	select {
+	case h.errC <- err:
+	default:
+	}
+}
+
+func TestUDPChannelConnected(t *testing.T) {
+	server, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	conn, err := net.DialUDP("udp4", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := NewUDPChannel(conn, &option.Options{})
+	if ch.RemoteAddress().String() != server.LocalAddr().String() {
+		t.Fatalf("expected remote %v, got %v", server.LocalAddr(), ch.RemoteAddress())
+	}
+
+	recorder := &recordHandler{readC: make(chan interface{}, 1)}
+	errs := &errorRecordHandler{errC: make(chan error, 1)}
+	ch.Pipeline().AddLast("record", recorder)
+	ch.Pipeline().AddLast("error", errs)
+
+	go func() {
+		_ = ch.Serve()
+	}()
+	defer ch.Close()
+
+	// reading the first datagram shows the channel is serving
+	if _, err := server.WriteToUDP([]byte("hello"), conn.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-recorder.readC:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for hello")
+	}
+
+	ch.Write(buffer.NewByteBuf([]byte("ping"), 0, 4))
+
+	_ = server.SetReadDeadline(time.Now().Add(time.Second))
+
+	b := make([]byte, 16)
+	n, raddr, err := server.ReadFromUDP(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b[:n]) != "ping" || raddr.String() != conn.LocalAddr().String() {
+		t.Fatalf("expected ping from %v, got %q from %v", conn.LocalAddr(), b[:n], raddr)
+	}
+
+	// the closed port answers with an ICMP port unreachable, which the channel passes to the pipeline
+	server.Close()
+	ch.Write(buffer.NewByteBuf([]byte("ping"), 0, 4))
+
+	select {
+	case err := <-errs.errC:
+		if !isConnRefused(err) {
+			t.Fatalf("expected %v, got %v", syscall.ECONNREFUSED, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for connection refused")
+	}
+}
+
+func TestUDPChannelUnconnected(t *testing.T) {
+	ch := newTestUDPChannel(t)
+	defer ch.conn.Close()
+
+	if raddr := ch.RemoteAddress(); raddr != nil {
+		t.Fatalf("expected no remote address, got %v", raddr)
+	}
+}
@@ -0,0 +1,267 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package channel
+
+import (
+	"net"
+	"ngio/buffer"
+	"syscall"
+	"unsafe"
+)
+
+// mmsghdr is struct mmsghdr of recvmmsg and sendmmsg.
+type mmsghdr struct {
+	hdr syscall.Msghdr
+	len uint32
+	_   [4]byte
+}
+
+// udpBatchIO reads and writes up to len(msgs) datagrams per syscall, reusing its buffers.
+type udpBatchIO struct {
+	raw  syscall.RawConn
+	ipv6 bool
+
+	// packetInfo is set with option.UDPPacketInfo, the local addresses are received and sent as control messages
+	packetInfo bool
+
+	msgs  []mmsghdr
+	iovs  []syscall.Iovec
+	names []syscall.RawSockaddrAny
+	bufs  [][]byte
+	oobs  [][]byte
+
+	wmsgs  []mmsghdr
+	wiovs  []syscall.Iovec
+	wnames []syscall.RawSockaddrAny
+	woobs  [][]byte
+}
+
+func newUDPBatchIO(conn *net.UDPConn, n, size, oobSize int, ipv6 bool) *udpBatchIO {
+	if n <= 1 {
+		return nil
+	}
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	b := &udpBatchIO{
+		raw:        raw,
+		ipv6:       ipv6,
+		packetInfo: oobSize > 0,
+		msgs:       make([]mmsghdr, n),
+		iovs:       make([]syscall.Iovec, n),
+		names:      make([]syscall.RawSockaddrAny, n),
+		bufs:       make([][]byte, n),
+		oobs:       make([][]byte, n),
+		wmsgs:      make([]mmsghdr, n),
+		wiovs:      make([]syscall.Iovec, n),
+		wnames:     make([]syscall.RawSockaddrAny, n),
+		woobs:      make([][]byte, n),
+	}
+
+	for i := 0; i < n; i++ {
+		b.bufs[i] = make([]byte, size)
+		if oobSize > 0 {
+			b.oobs[i] = make([]byte, oobSize)
+		}
+	}
+
+	return b
+}
+
+// read receives at least one datagram, blocking until one arrives, and returns how many it received.
+func (b *udpBatchIO) read() (int, error) {
+	for i := range b.msgs {
+		b.iovs[i].Base = &b.bufs[i][0]
+		b.iovs[i].SetLen(len(b.bufs[i]))
+
+		h := &b.msgs[i].hdr
+		h.Name = (*byte)(unsafe.Pointer(&b.names[i]))
+		h.Namelen = syscall.SizeofSockaddrAny
+		h.Iov = &b.iovs[i]
+		h.Iovlen = 1
+		h.Control = nil
+		h.SetControllen(0)
+		h.Flags = 0
+
+		if len(b.oobs[i]) > 0 {
+			h.Control = &b.oobs[i][0]
+			h.SetControllen(len(b.oobs[i]))
+		}
+	}
+
+	var n int
+	var errno syscall.Errno
+
+	err := b.raw.Read(func(fd uintptr) bool {
+		r, _, e := syscall.Syscall6(sysRECVMMSG, fd, uintptr(unsafe.Pointer(&b.msgs[0])), uintptr(len(b.msgs)), 0, 0, 0)
+		if e == syscall.EAGAIN {
+			return false
+		}
+
+		n, errno = int(r), e
+		return true
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return n, nil
+}
+
+// packet returns the i-th datagram of the last read, its bytes are copied out of the reused buffer by alloc.
+func (b *udpBatchIO) packet(i int, port int, alloc *buffer.DatagramAllocator) *buffer.DatagramPacket {
+	m := &b.msgs[i]
+	raddr := sockaddrToUDPAddr(&b.names[i])
+
+	var laddr *net.UDPAddr
+	if b.packetInfo {
+		if ip := parsePacketInfo(b.oobs[i][:m.hdr.Controllen]); ip != nil {
+			laddr = &net.UDPAddr{IP: ip, Port: port}
+		}
+	}
+
+	return buffer.NewAddressedDatagramPacket(laddr, raddr, alloc.Allocate(b.bufs[i][:m.len]))
+}
+
+// write sends the packets, as many per syscall as the batch holds. A packet the socket refuses is
+// reported to failed and the rest are still sent, only an error of the socket itself is returned.
+func (b *udpBatchIO) write(packets []*buffer.DatagramPacket, failed func(err error)) error {
+	for len(packets) > 0 {
+		n := len(packets)
+		if n > len(b.wmsgs) {
+			n = len(b.wmsgs)
+		}
+
+		for i, packet := range packets[:n] {
+			data := packet.ByteBuf().ReadBytes(packet.ByteBuf().ReadableBytes())
+
+			b.wiovs[i].Base = nil
+			if len(data) > 0 {
+				b.wiovs[i].Base = &data[0]
+			}
+			b.wiovs[i].SetLen(len(data))
+
+			h := &b.wmsgs[i].hdr
+			h.Name = nil
+			h.Namelen = 0
+
+			// connected sockets send without a destination
+			if raddr := packet.RemoteAddress(); raddr != nil {
+				h.Name = (*byte)(unsafe.Pointer(&b.wnames[i]))
+				h.Namelen = putUDPAddr(&b.wnames[i], raddr, b.ipv6)
+			}
+
+			h.Iov = &b.wiovs[i]
+			h.Iovlen = 1
+			h.Control = nil
+			h.SetControllen(0)
+			h.Flags = 0
+
+			b.woobs[i] = nil
+			if laddr := packet.LocalAddress(); b.packetInfo && laddr != nil && !laddr.IP.IsUnspecified() {
+				b.woobs[i] = marshalPacketInfo(laddr.IP, b.ipv6)
+			}
+
+			if len(b.woobs[i]) > 0 {
+				h.Control = &b.woobs[i][0]
+				h.SetControllen(len(b.woobs[i]))
+			}
+		}
+
+		for off := 0; off < n; {
+			var sent int
+			var errno syscall.Errno
+
+			err := b.raw.Write(func(fd uintptr) bool {
+				r, _, e := syscall.Syscall6(sysSENDMMSG, fd, uintptr(unsafe.Pointer(&b.wmsgs[off])), uintptr(n-off), 0, 0, 0)
+				if e == syscall.EAGAIN {
+					return false
+				}
+
+				sent, errno = int(r), e
+				return true
+			})
+
+			if err != nil {
+				return err
+			}
+
+			// sendmmsg fails only if the first message could not be sent, skip it and send the others
+			if errno != 0 {
+				failed(errno)
+				off++
+				continue
+			}
+
+			off += sent
+		}
+
+		packets = packets[n:]
+	}
+
+	return nil
+}
+
+func sockaddrToUDPAddr(rsa *syscall.RawSockaddrAny) *net.UDPAddr {
+	switch rsa.Addr.Family {
+	case syscall.AF_INET:
+		sa := (*syscall.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		p := (*[2]byte)(unsafe.Pointer(&sa.Port))
+
+		return &net.UDPAddr{IP: net.IPv4(sa.Addr[0], sa.Addr[1], sa.Addr[2], sa.Addr[3]), Port: int(p[0])<<8 + int(p[1])}
+	case syscall.AF_INET6:
+		sa := (*syscall.RawSockaddrInet6)(unsafe.Pointer(rsa))
+		p := (*[2]byte)(unsafe.Pointer(&sa.Port))
+
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, sa.Addr[:])
+
+		addr := &net.UDPAddr{IP: ip, Port: int(p[0])<<8 + int(p[1])}
+		if sa.Scope_id != 0 {
+			if ifi, err := net.InterfaceByIndex(int(sa.Scope_id)); err == nil {
+				addr.Zone = ifi.Name
+			}
+		}
+
+		return addr
+	}
+
+	return nil
+}
+
+// putUDPAddr writes addr into rsa in the family of the socket and returns its length.
+func putUDPAddr(rsa *syscall.RawSockaddrAny, addr *net.UDPAddr, ipv6 bool) uint32 {
+	if !ipv6 {
+		sa := (*syscall.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		sa.Family = syscall.AF_INET
+		p := (*[2]byte)(unsafe.Pointer(&sa.Port))
+		p[0], p[1] = byte(addr.Port>>8), byte(addr.Port)
+		copy(sa.Addr[:], addr.IP.To4())
+
+		return syscall.SizeofSockaddrInet4
+	}
+
+	sa := (*syscall.RawSockaddrInet6)(unsafe.Pointer(rsa))
+	*sa = syscall.RawSockaddrInet6{Family: syscall.AF_INET6}
+	p := (*[2]byte)(unsafe.Pointer(&sa.Port))
+	p[0], p[1] = byte(addr.Port>>8), byte(addr.Port)
+	copy(sa.Addr[:], addr.IP.To16())
+
+	if addr.Zone != "" {
+		if ifi, err := net.InterfaceByName(addr.Zone); err == nil {
+			sa.Scope_id = uint32(ifi.Index)
+		}
+	}
+
+	return syscall.SizeofSockaddrInet6
+}
@@ -0,0 +1,7 @@
+package channel
+
+// the numbers of arch/x86/entry/syscalls/syscall_64.tbl, syscall has no SYS_SENDMMSG on linux/amd64.
+const (
+	sysRECVMMSG = 299
+	sysSENDMMSG = 307
+)
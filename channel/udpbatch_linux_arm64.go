@@ -0,0 +1,9 @@
+package channel
+
+import "syscall"
+
+// the numbers of include/uapi/asm-generic/unistd.h.
+const (
+	sysRECVMMSG = syscall.SYS_RECVMMSG
+	sysSENDMMSG = syscall.SYS_SENDMMSG
+)
@@ -0,0 +1,148 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package channel
+
+import (
+	"net"
+	"ngio/buffer"
+	"ngio/option"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestUDPBatchSockaddr(t *testing.T) {
+	for _, addr := range []*net.UDPAddr{
+		{IP: net.IPv4(127, 0, 0, 1), Port: 5353},
+		{IP: net.ParseIP("::1"), Port: 65535},
+	} {
+		var rsa syscall.RawSockaddrAny
+		putUDPAddr(&rsa, addr, addr.IP.To4() == nil)
+
+		if got := sockaddrToUDPAddr(&rsa); !got.IP.Equal(addr.IP) || got.Port != addr.Port {
+			t.Fatalf("expected %v, got %v", addr, got)
+		}
+	}
+}
+
+func TestUDPBatchRoundTrip(t *testing.T) {
+	for _, packetInfo := range []bool{false, true} {
+		receiver, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer receiver.Close()
+
+		sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sender.Close()
+
+		oobSize := 0
+		if packetInfo {
+			oobSize = packetInfoSize
+			if err := option.SetupUDPOptions(receiver, &option.Options{UDPPacketInfo: true}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		rb := newUDPBatchIO(receiver, 4, 64, oobSize, false)
+		sb := newUDPBatchIO(sender, 2, 64, oobSize, false)
+
+		// more packets than the batch holds, sendmmsg is called twice
+		raddr := receiver.LocalAddr().(*net.UDPAddr)
+		payloads := []string{"a", "bb", "ccc"}
+
+		var packets []*buffer.DatagramPacket
+		for _, p := range payloads {
+			packets = append(packets, buffer.NewDatagramPacket(raddr, buffer.NewByteBuf([]byte(p), 0, len(p))))
+		}
+
+		if err := sb.write(packets, func(err error) { t.Fatal(err) }); err != nil {
+			t.Fatal(err)
+		}
+
+		_ = receiver.SetReadDeadline(time.Now().Add(time.Second))
+
+		alloc := buffer.NewDatagramAllocator(buffer.DefaultMaximum)
+
+		var got []*buffer.DatagramPacket
+		for len(got) < len(payloads) {
+			n, err := rb.read()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < n; i++ {
+				got = append(got, rb.packet(i, raddr.Port, alloc))
+			}
+		}
+
+		for i, packet := range got {
+			if s := string(packet.ByteBuf().ReadBytes(packet.ByteBuf().ReadableBytes())); s != payloads[i] {
+				t.Fatalf("expected %v, got %v", payloads[i], s)
+			}
+
+			if packet.RemoteAddress().String() != sender.LocalAddr().String() {
+				t.Fatalf("expected remote %v, got %v", sender.LocalAddr(), packet.RemoteAddress())
+			}
+
+			if laddr := packet.LocalAddress(); packetInfo != (laddr != nil) {
+				t.Fatalf("packet info %v, got local address %v", packetInfo, laddr)
+			} else if laddr != nil && !laddr.IP.Equal(raddr.IP) {
+				t.Fatalf("expected local %v, got %v", raddr.IP, laddr.IP)
+			}
+		}
+	}
+}
+
+func TestUDPBatchWriteFailure(t *testing.T) {
+	receiver, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	sb := newUDPBatchIO(sender, 4, 64, 0, false)
+
+	// the first datagram is too large to be sent, the ones after it still are
+	raddr := receiver.LocalAddr().(*net.UDPAddr)
+	payloads := [][]byte{make([]byte, 70000), []byte("a"), make([]byte, 70000), []byte("bb")}
+
+	var packets []*buffer.DatagramPacket
+	for _, p := range payloads {
+		packets = append(packets, buffer.NewDatagramPacket(raddr, buffer.NewByteBuf(p, 0, len(p))))
+	}
+
+	var failures []error
+	if err := sb.write(packets, func(err error) { failures = append(failures, err) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failures) != 2 || failures[0] != syscall.EMSGSIZE {
+		t.Fatalf("expected two %v, got %v", syscall.EMSGSIZE, failures)
+	}
+
+	_ = receiver.SetReadDeadline(time.Now().Add(time.Second))
+
+	b := make([]byte, 64)
+	for _, expected := range []string{"a", "bb"} {
+		n, err := receiver.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b[:n]) != expected {
+			t.Fatalf("expected %v, got %v", expected, string(b[:n]))
+		}
+	}
+}
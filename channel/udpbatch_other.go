@@ -0,0 +1,29 @@
+//go:build !linux || (!amd64 && !arm64)
+// +build !linux !amd64,!arm64
+
+package channel
+
+import (
+	"net"
+	"ngio/buffer"
+)
+
+// udpBatchIO is only available on linux/amd64 and linux/arm64, elsewhere datagrams are read
+// and written one per syscall.
+type udpBatchIO struct{}
+
+func newUDPBatchIO(conn *net.UDPConn, n, size, oobSize int, ipv6 bool) *udpBatchIO {
+	return nil
+}
+
+func (b *udpBatchIO) read() (int, error) {
+	return 0, nil
+}
+
+func (b *udpBatchIO) packet(i int, port int, alloc *buffer.DatagramAllocator) *buffer.DatagramPacket {
+	return nil
+}
+
+func (b *udpBatchIO) write(packets []*buffer.DatagramPacket, failed func(err error)) error {
+	return nil
+}
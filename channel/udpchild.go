@@ -0,0 +1,309 @@
+package channel
+
+import (
+	"bytes"
+	"net"
+	"ngio/buffer"
+	"ngio/logger"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UDPChildChannel is the channel of one peer of a UDPChannel, created by UDPChildDemux on the
+// first datagram from that peer. It receives the peer's buffer.DatagramPacket and writes
+// buffer.ByteBuffer or buffer.DatagramPacket back to it through the parent's socket.
+type UDPChildChannel struct {
+	id           uint32
+	isActive     bool
+	parent       *UDPChannel
+	laddr, raddr *net.UDPAddr
+	demux        *UDPChildDemux
+	activeOnce   sync.Once
+	readyC       chan struct{}
+	closed       int32
+	scheduler    Scheduler
+	lastActive   int64
+	quitC        chan error
+	pipeline     *Pipeline
+	attributes   Attributes
+	log          logger.Logger
+}
+
+func newUDPChildChannel(parent *UDPChannel, demux *UDPChildDemux, laddr, raddr *net.UDPAddr) *UDPChildChannel {
+	scheduler := SchedulerOf(parent)
+
+	ch := &UDPChildChannel{
+		id:         atomic.AddUint32(&udpChannelId, 1),
+		isActive:   false,
+		parent:     parent,
+		laddr:      laddr,
+		raddr:      raddr,
+		demux:      demux,
+		scheduler:  scheduler,
+		lastActive: scheduler.Now().UnixNano(),
+		readyC:     make(chan struct{}),
+		quitC:      make(chan error, 1),
+		attributes: NewDefaultAttributes(),
+		log:        logger.DefaultLogger(),
+	}
+
+	ch.pipeline = NewPipeline(ch)
+	return ch
+}
+
+func (ch *UDPChildChannel) Id() uint32 {
+	return ch.id
+}
+
+func (ch *UDPChildChannel) IsActive() bool {
+	return ch.isActive
+}
+
+func (ch *UDPChildChannel) Pipeline() *Pipeline {
+	return ch.pipeline
+}
+
+// LocalAddress returns the address the peer's first datagram arrived on if option.UDPPacketInfo
+// is set, the parent's bound address otherwise.
+func (ch *UDPChildChannel) LocalAddress() net.Addr {
+	if ch.laddr != nil {
+		return ch.laddr
+	}
+
+	return ch.parent.LocalAddress()
+}
+
+func (ch *UDPChildChannel) RemoteAddress() net.Addr {
+	return ch.raddr
+}
+
+func (ch *UDPChildChannel) Attributes() Attributes {
+	return ch.attributes
+}
+
+// Parent returns the channel of the socket the child's datagrams are read from and written to.
+func (ch *UDPChildChannel) Parent() *UDPChannel {
+	return ch.parent
+}
+
+func (ch *UDPChildChannel) Serve() error {
+	defer ch.log.Debugf("[%v] close", ch)
+
+	ch.activate()
+
+	return <-ch.quitC
+}
+
+func (ch *UDPChildChannel) activate() {
+	ch.activeOnce.Do(func() {
+		ch.isActive = true
+
+		ch.log.Debugf("[%v] serve", ch)
+
+		ch.pipeline.FireActiveHandler()
+	})
+}
+
+func (ch *UDPChildChannel) Write(msg interface{}) {
+	if !ch.isActive {
+		// todo: logger
+		return
+	}
+
+	ch.touch()
+
+	switch m := msg.(type) {
+	case buffer.ByteBuffer:
+		ch.parent.Write(buffer.NewAddressedDatagramPacket(ch.laddr, ch.raddr, m))
+	case *buffer.DatagramPacket:
+		ch.parent.Write(m)
+	default:
+		// todo: logger
+	}
+}
+
+func (ch *UDPChildChannel) Close() {
+	if !atomic.CompareAndSwapInt32(&ch.closed, 0, 1) {
+		return
+	}
+
+	ch.demux.remove(ch)
+
+	if ch.isActive {
+		ch.isActive = false
+		ch.pipeline.FireInActiveHandler()
+	}
+
+	ch.quitC <- nil
+}
+
+func (ch *UDPChildChannel) String() string {
+	buf := bytes.Buffer{}
+
+	buf.WriteString("channel id: ")
+	buf.WriteString(strconv.FormatInt(int64(ch.id), 10))
+	buf.WriteString(", network: ")
+	buf.WriteString(ch.raddr.Network())
+	buf.WriteString(", remote: ")
+	buf.WriteString(ch.raddr.String())
+	buf.WriteString(", active: ")
+	buf.WriteString(strconv.FormatBool(ch.isActive))
+
+	return buf.String()
+}
+
+func (ch *UDPChildChannel) touch() {
+	atomic.StoreInt64(&ch.lastActive, ch.scheduler.Now().UnixNano())
+}
+
+func (ch *UDPChildChannel) idle() time.Duration {
+	return ch.scheduler.Now().Sub(time.Unix(0, atomic.LoadInt64(&ch.lastActive)))
+}
+
+// UDPChildDemux is the handler of a UDPChannel which demultiplexes the received datagrams by
+// remote address into UDPChildChannel. The initializer is called with each new child, which is
+// closed after idleTimeout without reads or writes. A zero idleTimeout keeps children until
+// they are closed or CloseChildren is called.
+//
+//	ch.Pipeline().AddLast("demux", channel.NewUDPChildDemux(30*time.Second, initializer))
+type UDPChildDemux struct {
+	idleTimeout time.Duration
+	initializer Initializer
+	mu          sync.Mutex
+	children    map[string]*UDPChildChannel
+	log         logger.Logger
+}
+
+func NewUDPChildDemux(idleTimeout time.Duration, initializer Initializer) *UDPChildDemux {
+	return &UDPChildDemux{
+		idleTimeout: idleTimeout,
+		initializer: initializer,
+		children:    make(map[string]*UDPChildChannel),
+		log:         logger.DefaultLogger(),
+	}
+}
+
+func (demux *UDPChildDemux) ChannelRead(ctx *Context, msg interface{}) {
+	packet, ok := msg.(*buffer.DatagramPacket)
+	if !ok {
+		ctx.FireReadHandler(msg)
+		return
+	}
+
+	parent, ok := ctx.Pipeline().Channel().(*UDPChannel)
+	if !ok {
+		ctx.FireReadHandler(msg)
+		return
+	}
+
+	child := demux.child(parent, packet)
+
+	// closed by its handlers, e.g. rejecting the peer in ChannelActive
+	if atomic.LoadInt32(&child.closed) == 1 {
+		return
+	}
+
+	child.touch()
+	child.pipeline.FireReadHandler(packet)
+}
+
+// child returns the child of the packet's sender, creating and activating it on the first packet.
+// The initializer and ChannelActive run without the lock, so handlers may close the child. If the
+// initializer panics, the child is closed and removed, the peer's next datagram creates a new one.
+func (demux *UDPChildDemux) child(parent *UDPChannel, packet *buffer.DatagramPacket) *UDPChildChannel {
+	key := packet.RemoteAddress().String()
+
+	demux.mu.Lock()
+
+	if child, ok := demux.children[key]; ok {
+		demux.mu.Unlock()
+
+		// created by another dispatch worker, which may not be done with it yet
+		<-child.readyC
+		return child
+	}
+
+	child := newUDPChildChannel(parent, demux, packet.LocalAddress(), packet.RemoteAddress())
+	demux.children[key] = child
+
+	demux.mu.Unlock()
+
+	ready := false
+	defer func() {
+		if !ready {
+			atomic.StoreInt32(&child.closed, 1)
+			demux.remove(child)
+		}
+
+		// the workers waiting for the child go on, even if it failed
+		close(child.readyC)
+	}()
+
+	if demux.initializer != nil {
+		demux.initializer(child)
+	}
+
+	// active before its first read, serve only waits for the close
+	child.activate()
+	ready = true
+
+	go func() {
+		_ = child.Serve()
+	}()
+
+	if demux.idleTimeout > 0 {
+		demux.watch(child, demux.idleTimeout)
+	}
+
+	return child
+}
+
+func (demux *UDPChildDemux) watch(child *UDPChildChannel, delay time.Duration) {
+	child.scheduler.Schedule(delay, func() {
+		if atomic.LoadInt32(&child.closed) == 1 {
+			return
+		}
+
+		if idle := child.idle(); idle < demux.idleTimeout {
+			demux.watch(child, demux.idleTimeout-idle)
+			return
+		}
+
+		demux.log.Debugf("[%v] idle timeout", child)
+		child.Close()
+	})
+}
+
+func (demux *UDPChildDemux) remove(child *UDPChildChannel) {
+	key := child.raddr.String()
+
+	demux.mu.Lock()
+	if demux.children[key] == child {
+		delete(demux.children, key)
+	}
+	demux.mu.Unlock()
+}
+
+// Children returns the number of open children.
+func (demux *UDPChildDemux) Children() int {
+	demux.mu.Lock()
+	defer demux.mu.Unlock()
+
+	return len(demux.children)
+}
+
+// CloseChildren closes all open children, e.g. once the parent channel is closed.
+func (demux *UDPChildDemux) CloseChildren() {
+	demux.mu.Lock()
+	children := make([]*UDPChildChannel, 0, len(demux.children))
+	for _, child := range demux.children {
+		children = append(children, child)
+	}
+	demux.mu.Unlock()
+
+	for _, child := range children {
+		child.Close()
+	}
+}
@@ -0,0 +1,171 @@
+package channel
+
+import (
+	"net"
+	"ngio/buffer"
+	"ngio/option"
+	"sync"
+	"testing"
+	"time"
+)
+
+type rejectHandler struct{}
+
+func (rejectHandler) ChannelActive(ctx *Context) {
+	ctx.Pipeline().Channel().Close()
+}
+
+func newTestUDPChannel(t *testing.T) *UDPChannel {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewUDPChannel(conn, &option.Options{})
+}
+
+func peerPacket(port int, payload string) *buffer.DatagramPacket {
+	return buffer.NewDatagramPacket(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, buffer.NewByteBuf([]byte(payload), 0, len(payload)))
+}
+
+func TestUDPChildDemux(t *testing.T) {
+	parent := newTestUDPChannel(t)
+	defer parent.conn.Close()
+
+	var mu sync.Mutex
+	recorders := make(map[string]*recordHandler)
+
+	demux := NewUDPChildDemux(0, func(ch Channel) {
+		if ch.RemoteAddress().(*net.UDPAddr).Port == 3 {
+			ch.Pipeline().AddLast("reject", rejectHandler{})
+			return
+		}
+
+		recorder := &recordHandler{readC: make(chan interface{}, 4)}
+		ch.Pipeline().AddLast("record", recorder)
+
+		mu.Lock()
+		recorders[ch.RemoteAddress().String()] = recorder
+		mu.Unlock()
+	})
+	parent.Pipeline().AddLast("demux", demux)
+
+	parent.Pipeline().FireReadHandler(peerPacket(1, "a1"))
+	parent.Pipeline().FireReadHandler(peerPacket(2, "b1"))
+	parent.Pipeline().FireReadHandler(peerPacket(1, "a2"))
+
+	if n := demux.Children(); n != 2 {
+		t.Fatalf("expected 2 children, got %d", n)
+	}
+
+	// each peer's datagrams are routed to its own child, in order
+	for addr, payloads := range map[string][]string{"127.0.0.1:1": {"a1", "a2"}, "127.0.0.1:2": {"b1"}} {
+		for _, payload := range payloads {
+			bf := (<-recorders[addr].readC).(*buffer.DatagramPacket).ByteBuf()
+			if got := string(bf.ReadBytes(bf.ReadableBytes())); got != payload {
+				t.Fatalf("%s: expected %s, got %s", addr, payload, got)
+			}
+		}
+	}
+
+	// a child closed in ChannelActive is removed without deadlocking the demux
+	done := make(chan struct{})
+	go func() {
+		parent.Pipeline().FireReadHandler(peerPacket(3, "c1"))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deadlock closing a child in ChannelActive")
+	}
+
+	if n := demux.Children(); n != 2 {
+		t.Fatalf("expected the rejected child to be removed, got %d children", n)
+	}
+
+	demux.CloseChildren()
+	if n := demux.Children(); n != 0 {
+		t.Fatalf("expected no children, got %d", n)
+	}
+
+	// the next datagram of a peer creates a new child
+	parent.Pipeline().FireReadHandler(peerPacket(1, "a3"))
+	if n := demux.Children(); n != 1 {
+		t.Fatalf("expected a new child, got %d children", n)
+	}
+}
+
+func TestUDPChildDemuxIdleTimeout(t *testing.T) {
+	parent := newTestUDPChannel(t)
+	defer parent.conn.Close()
+
+	demux := NewUDPChildDemux(50*time.Millisecond, nil)
+	parent.Pipeline().AddLast("demux", demux)
+
+	parent.Pipeline().FireReadHandler(peerPacket(1, "a1"))
+	parent.Pipeline().FireReadHandler(peerPacket(2, "b1"))
+
+	// the reads of peer 1 keep its child open
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		parent.Pipeline().FireReadHandler(peerPacket(1, "a"))
+	}
+
+	if n := demux.Children(); n != 1 {
+		t.Fatalf("expected the idle child to be closed, got %d children", n)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if n := demux.Children(); n != 0 {
+		t.Fatalf("expected no children, got %d", n)
+	}
+}
+
+func TestUDPChildDemuxInitializerPanic(t *testing.T) {
+	parent := newTestUDPChannel(t)
+	defer parent.conn.Close()
+
+	enteredC, releaseC := make(chan struct{}), make(chan struct{})
+	panicked := false
+
+	demux := NewUDPChildDemux(0, func(ch Channel) {
+		if !panicked {
+			panicked = true
+			close(enteredC)
+			<-releaseC
+			panic("initializer failed")
+		}
+	})
+	parent.Pipeline().AddLast("demux", demux)
+
+	go parent.Pipeline().FireReadHandler(peerPacket(1, "a1"))
+	<-enteredC
+
+	// a second worker waits for the child the first one is creating
+	done := make(chan struct{})
+	go func() {
+		parent.Pipeline().FireReadHandler(peerPacket(1, "a2"))
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(releaseC)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("blocked on a child whose initializer panicked")
+	}
+
+	if n := demux.Children(); n != 0 {
+		t.Fatalf("expected the failed child to be removed, got %d children", n)
+	}
+
+	// the next datagram of the peer creates a new child
+	parent.Pipeline().FireReadHandler(peerPacket(1, "a3"))
+	if n := demux.Children(); n != 1 {
+		t.Fatalf("expected a new child, got %d children", n)
+	}
+}
@@ -0,0 +1,186 @@
+package channel
+
+import (
+	"hash/fnv"
+	"ngio/buffer"
+	"ngio/option"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultUDPQueueSize = 1024
+
+// udpDispatcher hands the datagrams read by a UDPChannel to its pipeline.
+type udpDispatcher interface {
+	dispatch(packet *buffer.DatagramPacket)
+	close()
+}
+
+func newUDPDispatcher(pipeline *Pipeline, dropped *uint64, opts *option.Options) udpDispatcher {
+	block := opts.UDPOverflow == option.UDPOverflowBlock
+
+	if opts.UDPDispatch == option.UDPDispatchGoroutine {
+		d := &goroutineDispatcher{
+			pipeline: pipeline,
+			block:    block,
+			dropped:  dropped,
+			closeC:   make(chan struct{}),
+		}
+
+		if opts.UDPQueueSize > 0 {
+			d.running = make(chan struct{}, opts.UDPQueueSize)
+		}
+
+		return d
+	}
+
+	workers := 1
+	if opts.UDPDispatch != option.UDPDispatchSerial {
+		workers = opts.UDPWorkers
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+	}
+
+	size := opts.UDPQueueSize
+	if size <= 0 {
+		size = defaultUDPQueueSize
+	}
+
+	d := &queueDispatcher{
+		pipeline: pipeline,
+		block:    block,
+		dropped:  dropped,
+		closeC:   make(chan struct{}),
+	}
+
+	// workers share one queue, or each one owns a queue of the peers hashed to it
+	queues := 1
+	if opts.UDPDispatch == option.UDPDispatchPerPeer {
+		queues = workers
+	}
+
+	d.queues = make([]chan *buffer.DatagramPacket, queues)
+	for i := range d.queues {
+		d.queues[i] = make(chan *buffer.DatagramPacket, size)
+	}
+
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.work(d.queues[i%queues])
+	}
+
+	return d
+}
+
+// goroutineDispatcher runs the pipeline on a goroutine per datagram, at most cap(running) at once
+// unless running is nil.
+type goroutineDispatcher struct {
+	pipeline  *Pipeline
+	running   chan struct{}
+	block     bool
+	dropped   *uint64
+	closeC    chan struct{}
+	closeOnce sync.Once
+}
+
+func (d *goroutineDispatcher) dispatch(packet *buffer.DatagramPacket) {
+	if d.running == nil {
+		go d.pipeline.FireReadHandler(packet)
+		return
+	}
+
+	if d.block {
+		select {
+		case d.running <- struct{}{}:
+		case <-d.closeC:
+			return
+		}
+	} else {
+		select {
+		case d.running <- struct{}{}:
+		default:
+			atomic.AddUint64(d.dropped, 1)
+			return
+		}
+	}
+
+	go func() {
+		defer func() {
+			<-d.running
+		}()
+
+		d.pipeline.FireReadHandler(packet)
+	}()
+}
+
+// close only releases a blocked dispatch, the running goroutines finish on their own.
+func (d *goroutineDispatcher) close() {
+	d.closeOnce.Do(func() {
+		close(d.closeC)
+	})
+}
+
+type queueDispatcher struct {
+	pipeline  *Pipeline
+	queues    []chan *buffer.DatagramPacket
+	block     bool
+	dropped   *uint64
+	closeC    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func (d *queueDispatcher) dispatch(packet *buffer.DatagramPacket) {
+	queue := d.queues[0]
+	if len(d.queues) > 1 {
+		queue = d.queues[d.index(packet)]
+	}
+
+	if d.block {
+		select {
+		case queue <- packet:
+		case <-d.closeC:
+		}
+		return
+	}
+
+	select {
+	case queue <- packet:
+	default:
+		atomic.AddUint64(d.dropped, 1)
+	}
+}
+
+func (d *queueDispatcher) index(packet *buffer.DatagramPacket) int {
+	raddr := packet.RemoteAddress()
+
+	h := fnv.New32a()
+	_, _ = h.Write(raddr.IP)
+	_, _ = h.Write([]byte{byte(raddr.Port >> 8), byte(raddr.Port)})
+
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+func (d *queueDispatcher) work(queue chan *buffer.DatagramPacket) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case packet := <-queue:
+			d.pipeline.FireReadHandler(packet)
+		case <-d.closeC:
+			return
+		}
+	}
+}
+
+// close stops the workers, datagrams still queued are dropped.
+func (d *queueDispatcher) close() {
+	d.closeOnce.Do(func() {
+		close(d.closeC)
+	})
+
+	d.wg.Wait()
+}
@@ -0,0 +1,150 @@
+package channel
+
+import (
+	"ngio/buffer"
+	"ngio/option"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// orderHandler records the payload byte of every datagram per peer port.
+type orderHandler struct {
+	mu    sync.Mutex
+	seen  map[int][]byte
+	count int32
+}
+
+func (h *orderHandler) ChannelRead(ctx *Context, msg interface{}) {
+	packet := msg.(*buffer.DatagramPacket)
+
+	h.mu.Lock()
+	h.seen[packet.RemoteAddress().Port] = append(h.seen[packet.RemoteAddress().Port], packet.ByteBuf().ReadByte())
+	h.mu.Unlock()
+
+	atomic.AddInt32(&h.count, 1)
+}
+
+// blockingHandler blocks every read until released.
+type blockingHandler struct {
+	startedC chan struct{}
+	releaseC chan struct{}
+}
+
+func (h *blockingHandler) ChannelRead(ctx *Context, msg interface{}) {
+	h.startedC <- struct{}{}
+	<-h.releaseC
+}
+
+func TestUDPDispatchPerPeerOrder(t *testing.T) {
+	handler := &orderHandler{seen: make(map[int][]byte)}
+	var dropped uint64
+
+	d := newUDPDispatcher(NewEmbeddedChannel(handler).Pipeline(), &dropped,
+		&option.Options{UDPDispatch: option.UDPDispatchPerPeer, UDPWorkers: 4, UDPOverflow: option.UDPOverflowBlock})
+	defer d.close()
+
+	for i := 0; i < 100; i++ {
+		for port := 1; port <= 8; port++ {
+			d.dispatch(peerPacket(port, string([]byte{byte(i)})))
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&handler.count) != 800 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	for port, seen := range handler.seen {
+		for i, b := range seen {
+			if int(b) != i {
+				t.Fatalf("peer %d: datagram %d arrived as %d", port, b, i)
+			}
+		}
+	}
+
+	if atomic.LoadInt32(&handler.count) != 800 || dropped != 0 {
+		t.Fatalf("expected 800 datagrams and none dropped, got %d and %d dropped", handler.count, dropped)
+	}
+}
+
+func TestUDPDispatchOverflow(t *testing.T) {
+	modes := []option.UDPDispatchMode{option.UDPDispatchGoroutine, option.UDPDispatchSerial, option.UDPDispatchWorkers, option.UDPDispatchPerPeer}
+
+	for _, mode := range modes {
+		handler := &blockingHandler{startedC: make(chan struct{}, 16), releaseC: make(chan struct{})}
+		var dropped uint64
+
+		// one datagram is handled, two are queued or running
+		opts := &option.Options{UDPDispatch: mode, UDPWorkers: 1, UDPQueueSize: 2}
+		if mode == option.UDPDispatchGoroutine {
+			opts.UDPQueueSize = 3
+		}
+
+		d := newUDPDispatcher(NewEmbeddedChannel(handler).Pipeline(), &dropped, opts)
+
+		d.dispatch(peerPacket(1, "x"))
+		<-handler.startedC
+
+		for i := 0; i < 9; i++ {
+			d.dispatch(peerPacket(1, "x"))
+		}
+
+		if n := atomic.LoadUint64(&dropped); n != 7 {
+			t.Errorf("mode %d: expected 7 dropped datagrams, got %d", mode, n)
+		}
+
+		close(handler.releaseC)
+		d.close()
+	}
+}
+
+func TestUDPDispatchOverflowBlock(t *testing.T) {
+	modes := []option.UDPDispatchMode{option.UDPDispatchGoroutine, option.UDPDispatchSerial}
+
+	for _, mode := range modes {
+		handler := &blockingHandler{startedC: make(chan struct{}, 16), releaseC: make(chan struct{})}
+		var dropped uint64
+
+		d := newUDPDispatcher(NewEmbeddedChannel(handler).Pipeline(), &dropped,
+			&option.Options{UDPDispatch: mode, UDPQueueSize: 1, UDPOverflow: option.UDPOverflowBlock})
+
+		d.dispatch(peerPacket(1, "x"))
+		<-handler.startedC
+
+		if mode == option.UDPDispatchSerial {
+			// fills the queue
+			d.dispatch(peerPacket(1, "x"))
+		}
+
+		doneC := make(chan struct{})
+		go func() {
+			d.dispatch(peerPacket(1, "x"))
+			close(doneC)
+		}()
+
+		select {
+		case <-doneC:
+			t.Fatalf("mode %d: expected dispatch to block", mode)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(handler.releaseC)
+
+		select {
+		case <-doneC:
+		case <-time.After(time.Second):
+			t.Fatalf("mode %d: expected dispatch to continue", mode)
+		}
+
+		if dropped != 0 {
+			t.Fatalf("mode %d: expected nothing dropped, got %d", mode, dropped)
+		}
+
+		d.close()
+	}
+}
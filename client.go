@@ -1,6 +1,7 @@
 package ngio
 
 import (
+	"net"
 	"ngio/channel"
 	"ngio/dialer"
 	"ngio/option"
@@ -24,6 +25,11 @@ func NewClient(network, laddr, raddr string) *Client {
 	}
 }
 
+// NewLocalClient creates a client connecting to the local server bound to name, see NewLocalServer.
+func NewLocalClient(name string) *Client {
+	return NewClient("local", "", name)
+}
+
 func (clt *Client) Option(opts ...option.Option) *Client {
 	for _, o := range opts {
 		o.Apply(clt.opts)
@@ -43,6 +49,8 @@ func (clt *Client) Dial() (err error) {
 		clt.dialer, err = dialer.NewTCPDialer(clt.network, clt.laddr, clt.raddr, clt.opts, clt.initializer)
 	case "udp", "udp4", "udp6":
 		clt.dialer, err = dialer.NewUDPDialer(clt.network, clt.laddr, clt.raddr, clt.opts, clt.initializer)
+	case "local":
+		clt.dialer, err = dialer.NewLocalDialer(clt.raddr, clt.opts, clt.initializer)
 	//case "ip", "ip4", "ip6":
 	default:
 		err = ErrUnsupportedNetwork
@@ -55,6 +63,21 @@ func (clt *Client) Dial() (err error) {
 	return clt.dialer.Dial()
 }
 
+// ServeConn serves an already connected conn instead of dialing the client's address.
+func (clt *Client) ServeConn(conn net.Conn) (err error) {
+	if udpConn, ok := conn.(*net.UDPConn); ok {
+		clt.dialer, err = dialer.FromUDPConn(udpConn, clt.opts, clt.initializer)
+	} else {
+		clt.dialer, err = dialer.FromConn(conn, clt.opts, clt.initializer)
+	}
+
+	if err != nil {
+		return
+	}
+
+	return clt.dialer.Dial()
+}
+
 func (clt *Client) Close() {
 	clt.dialer.Close()
 }
@@ -0,0 +1,120 @@
+package ngio
+
+import (
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+	"time"
+)
+
+// replyHandler passes what it reads to readC and answers pong.
+type replyHandler struct {
+	readC chan string
+}
+
+func (handler replyHandler) ChannelRead(ctx *channel.Context, msg interface{}) {
+	var bf buffer.ByteBuffer
+	switch m := msg.(type) {
+	case *buffer.DatagramPacket:
+		bf = m.ByteBuf()
+	case buffer.ByteBuffer:
+		bf = m
+	}
+
+	handler.readC <- string(bf.ReadBytes(bf.ReadableBytes()))
+	ctx.Write(buffer.NewByteBuf([]byte("pong"), 0, 4))
+}
+
+func serveConn(conn net.Conn) (*Client, chan string, chan error) {
+	readC := make(chan string, 1)
+	clt := NewClient("", "", "").Channel(func(ch channel.Channel) {
+		ch.Pipeline().AddLast("reply", replyHandler{readC: readC})
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- clt.ServeConn(conn)
+	}()
+
+	return clt, readC, served
+}
+
+func expectPing(t *testing.T, readC chan string) {
+	select {
+	case msg := <-readC:
+		if msg != "ping" {
+			t.Fatalf("expected ping, got %v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ping")
+	}
+}
+
+func TestClientServeConn(t *testing.T) {
+	clientConn, peer := net.Pipe()
+	defer peer.Close()
+
+	_, readC, served := serveConn(clientConn)
+
+	_ = peer.SetDeadline(time.Now().Add(time.Second))
+
+	if _, err := peer.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	expectPing(t, readC)
+
+	b := make([]byte, 16)
+	if n, err := peer.Read(b); err != nil || string(b[:n]) != "pong" {
+		t.Fatalf("expected pong, got %q, %v", b[:n], err)
+	}
+
+	// the peer closing ends the channel
+	_ = peer.Close()
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ServeConn to return")
+	}
+}
+
+func TestClientServeUDPConn(t *testing.T) {
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	clientConn, err := net.DialUDP("udp4", nil, peer.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clt, readC, served := serveConn(clientConn)
+
+	_ = peer.SetDeadline(time.Now().Add(time.Second))
+
+	if _, err := peer.WriteToUDP([]byte("ping"), clientConn.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectPing(t, readC)
+
+	b := make([]byte, 16)
+	if n, err := peer.Read(b); err != nil || string(b[:n]) != "pong" {
+		t.Fatalf("expected pong, got %q, %v", b[:n], err)
+	}
+
+	clt.Close()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ServeConn to return")
+	}
+}
@@ -79,6 +79,20 @@ func (adapter *ByteToMessageDecoderAdapter) ChannelRead(ctx *channel.Context, in
 	}
 }
 
+// DrainInboundBuffer returns the bytes not decoded yet and leaves the decoder empty.
+// Called while decoding, the decode loop stops.
+func (adapter *ByteToMessageDecoderAdapter) DrainInboundBuffer() buffer.ByteBuffer {
+	if adapter.remained == nil || adapter.remained.ReadableBytes() == 0 {
+		return nil
+	}
+
+	n := adapter.remained.ReadableBytes()
+	b := make([]byte, n)
+	copy(b, adapter.remained.ReadBytes(n))
+
+	return buffer.NewByteBuf(b, 0, n)
+}
+
 //MessageToMessageEncoderAdapter
 type MessageToMessageEncoderAdapter struct {
 	encoder MessageToMessageEncoder
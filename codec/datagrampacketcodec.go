@@ -0,0 +1,90 @@
+package codec
+
+import (
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+)
+
+// AddressedEnvelope is a message of a UDP pipeline together with its addresses. Decoded datagrams
+// carry the remote Sender and the local Recipient, written envelopes are sent to Recipient, from
+// Sender if it is set and option.UDPPacketInfo is enabled.
+type AddressedEnvelope struct {
+	Content   interface{}
+	Sender    *net.UDPAddr
+	Recipient *net.UDPAddr
+}
+
+func NewAddressedEnvelope(content interface{}, sender, recipient *net.UDPAddr) *AddressedEnvelope {
+	return &AddressedEnvelope{
+		Content:   content,
+		Sender:    sender,
+		Recipient: recipient,
+	}
+}
+
+// DatagramPacketDecoder runs a decoder on the payload of each *buffer.DatagramPacket and wraps
+// the decoded messages into AddressedEnvelope. Other messages are passed on unchanged.
+//
+//	ch.Pipeline().AddLast("decoder", codec.NewMessageToMessageDecoderAdapter(codec.NewDatagramPacketDecoder(decoder)))
+type DatagramPacketDecoder struct {
+	decoder MessageToMessageDecoder
+}
+
+func NewDatagramPacketDecoder(decoder MessageToMessageDecoder) *DatagramPacketDecoder {
+	if decoder == nil {
+		panic(ErrDecoderIsNil)
+	}
+
+	return &DatagramPacketDecoder{
+		decoder: decoder,
+	}
+}
+
+func (decoder *DatagramPacketDecoder) Decode(ctx *channel.Context, in interface{}) []interface{} {
+	packet, ok := in.(*buffer.DatagramPacket)
+	if !ok {
+		return []interface{}{in}
+	}
+
+	outs := decoder.decoder.Decode(ctx, packet.ByteBuf())
+	for i, out := range outs {
+		outs[i] = NewAddressedEnvelope(out, packet.RemoteAddress(), packet.LocalAddress())
+	}
+
+	return outs
+}
+
+// DatagramPacketEncoder runs an encoder on the content of each AddressedEnvelope and writes the
+// encoded bytes as a *buffer.DatagramPacket. Other messages are passed on unchanged. Like
+// MessageToByteEncoderAdapter, it writes nothing when the encoder produces nothing.
+//
+//	ch.Pipeline().AddLast("encoder", codec.NewDatagramPacketEncoder(encoder))
+type DatagramPacketEncoder struct {
+	encoder MessageToByteEncoder
+}
+
+func NewDatagramPacketEncoder(encoder MessageToByteEncoder) *DatagramPacketEncoder {
+	if encoder == nil {
+		panic(ErrEncoderIsNil)
+	}
+
+	return &DatagramPacketEncoder{
+		encoder: encoder,
+	}
+}
+
+func (encoder *DatagramPacketEncoder) Write(ctx *channel.Context, msg interface{}) {
+	envelope, ok := msg.(*AddressedEnvelope)
+	if !ok {
+		ctx.Write(msg)
+		return
+	}
+
+	out := encoder.encoder.Encode(ctx, envelope.Content)
+	if out == nil {
+		return
+	}
+
+	ctx.Write(buffer.NewAddressedDatagramPacket(envelope.Sender, envelope.Recipient, out))
+}
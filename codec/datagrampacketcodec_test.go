@@ -0,0 +1,74 @@
+package codec
+
+import (
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+)
+
+type stringDecoder struct{}
+
+func (stringDecoder) Decode(ctx *channel.Context, in interface{}) []interface{} {
+	bf := in.(buffer.ByteBuffer)
+	return []interface{}{string(bf.ReadBytes(bf.ReadableBytes()))}
+}
+
+type stringEncoder struct{}
+
+func (stringEncoder) Encode(ctx *channel.Context, in interface{}) buffer.ByteBuffer {
+	if in == "" {
+		return nil
+	}
+
+	b := []byte(in.(string))
+	return buffer.NewByteBuf(b, 0, len(b))
+}
+
+func TestDatagramPacketCodec(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(
+		NewMessageToMessageDecoderAdapter(NewDatagramPacketDecoder(stringDecoder{})),
+		NewDatagramPacketEncoder(stringEncoder{}))
+
+	local := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9000}
+	remote := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9001}
+
+	if !ch.WriteInbound(buffer.NewAddressedDatagramPacket(local, remote, buffer.NewByteBuf([]byte("ping"), 0, 4))) {
+		t.Fatal("expected a decoded message")
+	}
+
+	envelope, ok := ch.ReadInbound().(*AddressedEnvelope)
+	if !ok {
+		t.Fatal("expected an addressed envelope")
+	}
+
+	if envelope.Content != "ping" || envelope.Sender != remote || envelope.Recipient != local {
+		t.Fatalf("unexpected envelope %+v", envelope)
+	}
+
+	if !ch.WriteOutbound(NewAddressedEnvelope("pong", envelope.Recipient, envelope.Sender)) {
+		t.Fatal("expected an encoded packet")
+	}
+
+	packet, ok := ch.ReadOutbound().(*buffer.DatagramPacket)
+	if !ok {
+		t.Fatal("expected a datagram packet")
+	}
+
+	if actual := string(packet.ByteBuf().ReadBytes(packet.ByteBuf().ReadableBytes())); actual != "pong" {
+		t.Fatalf("expected %q, got %q", "pong", actual)
+	}
+
+	if packet.RemoteAddress() != remote || packet.LocalAddress() != local {
+		t.Fatalf("unexpected addresses %v, %v", packet.LocalAddress(), packet.RemoteAddress())
+	}
+
+	// nothing encoded, nothing written
+	if ch.WriteOutbound(NewAddressedEnvelope("", envelope.Recipient, envelope.Sender)) {
+		t.Fatalf("expected no packet, got %v", ch.ReadOutbound())
+	}
+
+	if err := ch.CheckError(); err != nil {
+		t.Fatal(err)
+	}
+}
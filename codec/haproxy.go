@@ -0,0 +1,536 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrHAProxyNoHeader      = errors.New("haproxy: connection does not start with a PROXY protocol header")
+	ErrHAProxyHeaderTooLong = errors.New("haproxy: header exceeds the maximum length")
+)
+
+type HAProxyCommand byte
+
+const (
+	// HAProxyCommandLocal is sent by the proxy for its own connections, like health checks,
+	// the addresses of the channel are the real ones
+	HAProxyCommandLocal HAProxyCommand = iota
+	HAProxyCommandProxy
+)
+
+type HAProxyProtocol byte
+
+const (
+	HAProxyProtocolUnknown HAProxyProtocol = iota
+	HAProxyProtocolTCP4
+	HAProxyProtocolTCP6
+	HAProxyProtocolUDP4
+	HAProxyProtocolUDP6
+	HAProxyProtocolUnixStream
+	HAProxyProtocolUnixDgram
+)
+
+// the types of the TLVs of v2 headers
+const (
+	HAProxyTLVTypeALPN       byte = 0x01
+	HAProxyTLVTypeAuthority  byte = 0x02
+	HAProxyTLVTypeCRC32C     byte = 0x03
+	HAProxyTLVTypeNoop       byte = 0x04
+	HAProxyTLVTypeUniqueID   byte = 0x05
+	HAProxyTLVTypeSSL        byte = 0x20
+	HAProxyTLVTypeSSLVersion byte = 0x21
+	HAProxyTLVTypeSSLCN      byte = 0x22
+	HAProxyTLVTypeSSLCipher  byte = 0x23
+	HAProxyTLVTypeSSLSigAlg  byte = 0x24
+	HAProxyTLVTypeSSLKeyAlg  byte = 0x25
+	HAProxyTLVTypeNetNS      byte = 0x30
+)
+
+// the client flags of the SSL TLV
+const (
+	HAProxySSLClientSSL      byte = 0x01
+	HAProxySSLClientCertConn byte = 0x02
+	HAProxySSLClientCertSess byte = 0x04
+)
+
+const (
+	haproxyV1MaxLength     = 107
+	haproxyV2HeaderLength  = 16
+	haproxyUnixAddrLength  = 108
+	haproxyV2VersionNibble = 0x20
+)
+
+type HAProxyTLV struct {
+	Type  byte
+	Value []byte
+}
+
+// HAProxySSL is the content of the SSL TLV, TLVs holds the SSL sub-TLVs like the version and the cipher.
+type HAProxySSL struct {
+	Client byte
+	Verify uint32
+	TLVs   []HAProxyTLV
+}
+
+// HAProxyMessage is a PROXY protocol header. Source and Destination are the addresses of the
+// connection the proxy accepted, they are nil for the LOCAL command and unknown protocols.
+type HAProxyMessage struct {
+	Version     int
+	Command     HAProxyCommand
+	Protocol    HAProxyProtocol
+	Source      net.Addr
+	Destination net.Addr
+	TLVs        []HAProxyTLV
+}
+
+// TLV returns the value of the first TLV of type typ.
+func (msg *HAProxyMessage) TLV(typ byte) ([]byte, bool) {
+	return findTLV(msg.TLVs, typ)
+}
+
+func (msg *HAProxyMessage) UniqueID() string {
+	v, _ := msg.TLV(HAProxyTLVTypeUniqueID)
+	return string(v)
+}
+
+// SSL returns the SSL TLV, sent if the client connected to the proxy over TLS.
+func (msg *HAProxyMessage) SSL() (*HAProxySSL, bool) {
+	v, ok := msg.TLV(HAProxyTLVTypeSSL)
+	if !ok || len(v) < 5 {
+		return nil, false
+	}
+
+	tlvs, err := parseTLVs(v[5:])
+	if err != nil {
+		return nil, false
+	}
+
+	return &HAProxySSL{Client: v[0], Verify: binary.BigEndian.Uint32(v[1:5]), TLVs: tlvs}, true
+}
+
+// SSLVersion returns the SSL_VERSION sub-TLV of the SSL TLV, like "TLSv1.3".
+func (ssl *HAProxySSL) SSLVersion() string {
+	v, _ := findTLV(ssl.TLVs, HAProxyTLVTypeSSLVersion)
+	return string(v)
+}
+
+func findTLV(tlvs []HAProxyTLV, typ byte) ([]byte, bool) {
+	for _, tlv := range tlvs {
+		if tlv.Type == typ {
+			return tlv.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+type haproxyMessageKey struct{}
+
+// HAProxyMessageOf returns the header decoded on ch, nil if there is none yet.
+func HAProxyMessageOf(ch channel.Channel) *HAProxyMessage {
+	msg, _ := ch.Attributes().Get(haproxyMessageKey{}).(*HAProxyMessage)
+	return msg
+}
+
+// RealRemoteAddress returns the address of the client behind the proxy, the remote address of ch
+// if no header told it.
+func RealRemoteAddress(ch channel.Channel) net.Addr {
+	if msg := HAProxyMessageOf(ch); msg != nil && msg.Source != nil {
+		return msg.Source
+	}
+
+	return ch.RemoteAddress()
+}
+
+// HAProxyMessageDecoder decodes the v1 or v2 PROXY protocol header a connection starts with into a
+// *HAProxyMessage, which it keeps as attribute of the channel, see RealRemoteAddress. Then it removes
+// itself and passes the bytes following the header on. A connection without a valid header is closed.
+//
+//	ch.Pipeline().AddFirst("haproxy", codec.NewByteToMessageDecoderAdapter(codec.NewHAProxyMessageDecoder()))
+type HAProxyMessageDecoder struct {
+	finished bool
+}
+
+func NewHAProxyMessageDecoder() *HAProxyMessageDecoder {
+	return &HAProxyMessageDecoder{}
+}
+
+func (decoder *HAProxyMessageDecoder) Decode(ctx *channel.Context, in buffer.ByteBuffer) interface{} {
+	// the bytes after the header, which arrived with it
+	if decoder.finished {
+		return in.ReadSlice(in.ReadableBytes())
+	}
+
+	prefix := in.GetBytes(in.ReaderIndex(), in.ReadableBytes())
+
+	var msg *HAProxyMessage
+	var n int
+	var err error
+
+	switch ProxyProtocolDetector().Detect(prefix) {
+	case DetectNeedMoreData:
+		return nil
+	case DetectNoMatch:
+		err = ErrHAProxyNoHeader
+	default:
+		if bytes.HasPrefix(prefix, ProxyProtocolV1Signature) {
+			msg, n, err = decodeHAProxyV1(prefix)
+		} else {
+			msg, n, err = decodeHAProxyV2(prefix)
+		}
+	}
+
+	if err != nil {
+		decoder.finished = true
+		in.Skip(in.ReadableBytes())
+		ctx.FireChannelErrorHandler(err)
+		ctx.Pipeline().Channel().Close()
+		return nil
+	}
+
+	if msg == nil {
+		return nil
+	}
+
+	in.Skip(n)
+	decoder.finished = true
+
+	ctx.Pipeline().Channel().Attributes().Set(haproxyMessageKey{}, msg)
+	ctx.Pipeline().Remove(ctx.Name())
+
+	return msg
+}
+
+// decodeHAProxyV1 decodes a header like "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+// It returns a nil message until the whole header arrived.
+func decodeHAProxyV1(b []byte) (*HAProxyMessage, int, error) {
+	end := bytes.Index(b, []byte("\r\n"))
+	if end == -1 {
+		if len(b) >= haproxyV1MaxLength {
+			return nil, 0, ErrHAProxyHeaderTooLong
+		}
+
+		return nil, 0, nil
+	}
+
+	if end+2 > haproxyV1MaxLength {
+		return nil, 0, ErrHAProxyHeaderTooLong
+	}
+
+	fields := strings.Split(string(b[:end]), " ")
+	msg := &HAProxyMessage{Version: 1, Command: HAProxyCommandProxy}
+
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return msg, end + 2, nil
+	}
+
+	if len(fields) != 6 {
+		return nil, 0, fmt.Errorf("haproxy: invalid v1 header %q", b[:end])
+	}
+
+	switch fields[1] {
+	case "TCP4":
+		msg.Protocol = HAProxyProtocolTCP4
+	case "TCP6":
+		msg.Protocol = HAProxyProtocolTCP6
+	default:
+		return nil, 0, fmt.Errorf("haproxy: invalid v1 protocol %q", fields[1])
+	}
+
+	src, srcErr := parseHAProxyV1Addr(fields[2], fields[4], msg.Protocol == HAProxyProtocolTCP4)
+	dst, dstErr := parseHAProxyV1Addr(fields[3], fields[5], msg.Protocol == HAProxyProtocolTCP4)
+
+	if srcErr != nil {
+		return nil, 0, srcErr
+	}
+
+	if dstErr != nil {
+		return nil, 0, dstErr
+	}
+
+	msg.Source, msg.Destination = src, dst
+
+	return msg, end + 2, nil
+}
+
+func parseHAProxyV1Addr(host, port string, ipv4 bool) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil || (ip.To4() != nil) != ipv4 {
+		return nil, fmt.Errorf("haproxy: invalid v1 address %q", host)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("haproxy: invalid v1 port %q", port)
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// decodeHAProxyV2 decodes a binary header. It returns a nil message until the whole header arrived.
+func decodeHAProxyV2(b []byte) (*HAProxyMessage, int, error) {
+	if len(b) < haproxyV2HeaderLength {
+		return nil, 0, nil
+	}
+
+	if b[12]&0xF0 != haproxyV2VersionNibble {
+		return nil, 0, fmt.Errorf("haproxy: invalid v2 version %d", b[12]>>4)
+	}
+
+	n := haproxyV2HeaderLength + int(binary.BigEndian.Uint16(b[14:16]))
+	if len(b) < n {
+		return nil, 0, nil
+	}
+
+	msg := &HAProxyMessage{Version: 2}
+
+	switch b[12] & 0x0F {
+	case 0x00:
+		msg.Command = HAProxyCommandLocal
+	case 0x01:
+		msg.Command = HAProxyCommandProxy
+	default:
+		return nil, 0, fmt.Errorf("haproxy: invalid v2 command %d", b[12]&0x0F)
+	}
+
+	family, transport := b[13]>>4, b[13]&0x0F
+	body := b[haproxyV2HeaderLength:n]
+
+	var addrLength int
+	switch {
+	case family == 0x01 && transport == 0x01:
+		msg.Protocol, addrLength = HAProxyProtocolTCP4, 12
+	case family == 0x01 && transport == 0x02:
+		msg.Protocol, addrLength = HAProxyProtocolUDP4, 12
+	case family == 0x02 && transport == 0x01:
+		msg.Protocol, addrLength = HAProxyProtocolTCP6, 36
+	case family == 0x02 && transport == 0x02:
+		msg.Protocol, addrLength = HAProxyProtocolUDP6, 36
+	case family == 0x03 && transport == 0x01:
+		msg.Protocol, addrLength = HAProxyProtocolUnixStream, 2*haproxyUnixAddrLength
+	case family == 0x03 && transport == 0x02:
+		msg.Protocol, addrLength = HAProxyProtocolUnixDgram, 2*haproxyUnixAddrLength
+	}
+
+	if len(body) < addrLength {
+		return nil, 0, fmt.Errorf("haproxy: v2 address block of %d bytes is too short", len(body))
+	}
+
+	// the addresses of LOCAL commands are ignored, the TLVs are still decoded
+	if msg.Command == HAProxyCommandProxy {
+		msg.Source, msg.Destination = parseHAProxyV2Addrs(msg.Protocol, body[:addrLength])
+	} else {
+		msg.Protocol = HAProxyProtocolUnknown
+	}
+
+	tlvs, err := parseTLVs(body[addrLength:])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	msg.TLVs = tlvs
+
+	return msg, n, nil
+}
+
+func parseHAProxyV2Addrs(protocol HAProxyProtocol, b []byte) (src, dst net.Addr) {
+	ipLength := net.IPv4len
+	if protocol == HAProxyProtocolTCP6 || protocol == HAProxyProtocolUDP6 {
+		ipLength = net.IPv6len
+	}
+
+	switch protocol {
+	case HAProxyProtocolTCP4, HAProxyProtocolTCP6, HAProxyProtocolUDP4, HAProxyProtocolUDP6:
+		srcIP := append(net.IP(nil), b[:ipLength]...)
+		dstIP := append(net.IP(nil), b[ipLength:2*ipLength]...)
+		srcPort := int(binary.BigEndian.Uint16(b[2*ipLength:]))
+		dstPort := int(binary.BigEndian.Uint16(b[2*ipLength+2:]))
+
+		if protocol == HAProxyProtocolTCP4 || protocol == HAProxyProtocolTCP6 {
+			return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}
+		}
+
+		return &net.UDPAddr{IP: srcIP, Port: srcPort}, &net.UDPAddr{IP: dstIP, Port: dstPort}
+	case HAProxyProtocolUnixStream, HAProxyProtocolUnixDgram:
+		network := "unix"
+		if protocol == HAProxyProtocolUnixDgram {
+			network = "unixgram"
+		}
+
+		return &net.UnixAddr{Name: unixPath(b[:haproxyUnixAddrLength]), Net: network},
+			&net.UnixAddr{Name: unixPath(b[haproxyUnixAddrLength:]), Net: network}
+	}
+
+	return nil, nil
+}
+
+func unixPath(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
+
+func parseTLVs(b []byte) ([]HAProxyTLV, error) {
+	var tlvs []HAProxyTLV
+
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, errors.New("haproxy: truncated TLV")
+		}
+
+		n := int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+n {
+			return nil, errors.New("haproxy: truncated TLV")
+		}
+
+		tlvs = append(tlvs, HAProxyTLV{Type: b[0], Value: append([]byte(nil), b[3:3+n]...)})
+		b = b[3+n:]
+	}
+
+	return tlvs, nil
+}
+
+// HAProxyMessageEncoder encodes the *HAProxyMessage written through it into a header of its Version,
+// for proxies telling their backends about the clients. Other messages are passed on unchanged.
+//
+//	ch.Pipeline().AddLast("haproxy", codec.NewMessageToMessageEncoderAdapter(codec.NewHAProxyMessageEncoder()))
+//	ch.Write(&codec.HAProxyMessage{Version: 2, Command: codec.HAProxyCommandProxy, Protocol: codec.HAProxyProtocolTCP4,
+//		Source: client.RemoteAddress(), Destination: client.LocalAddress()})
+type HAProxyMessageEncoder struct{}
+
+func NewHAProxyMessageEncoder() *HAProxyMessageEncoder {
+	return &HAProxyMessageEncoder{}
+}
+
+func (encoder *HAProxyMessageEncoder) Encode(ctx *channel.Context, in interface{}) []interface{} {
+	msg, ok := in.(*HAProxyMessage)
+	if !ok {
+		return []interface{}{in}
+	}
+
+	var b []byte
+	if msg.Version == 1 {
+		b = encodeHAProxyV1(msg)
+	} else {
+		b = encodeHAProxyV2(msg)
+	}
+
+	return []interface{}{buffer.NewByteBuf(b, 0, len(b))}
+}
+
+func encodeHAProxyV1(msg *HAProxyMessage) []byte {
+	src, srcOK := msg.Source.(*net.TCPAddr)
+	dst, dstOK := msg.Destination.(*net.TCPAddr)
+
+	var protocol string
+	switch msg.Protocol {
+	case HAProxyProtocolTCP4:
+		protocol = "TCP4"
+	case HAProxyProtocolTCP6:
+		protocol = "TCP6"
+	}
+
+	if protocol == "" || !srcOK || !dstOK {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", protocol, src.IP, dst.IP, src.Port, dst.Port))
+}
+
+func encodeHAProxyV2(msg *HAProxyMessage) []byte {
+	var body bytes.Buffer
+	var familyTransport byte
+
+	if msg.Command == HAProxyCommandProxy {
+		familyTransport = encodeHAProxyV2Addrs(&body, msg.Protocol, msg.Source, msg.Destination)
+	}
+
+	for _, tlv := range msg.TLVs {
+		body.WriteByte(tlv.Type)
+		_ = binary.Write(&body, binary.BigEndian, uint16(len(tlv.Value)))
+		body.Write(tlv.Value)
+	}
+
+	b := make([]byte, 0, haproxyV2HeaderLength+body.Len())
+	b = append(b, ProxyProtocolV2Signature...)
+	b = append(b, haproxyV2VersionNibble|byte(msg.Command), familyTransport, byte(body.Len()>>8), byte(body.Len()))
+
+	return append(b, body.Bytes()...)
+}
+
+// encodeHAProxyV2Addrs writes the address block and returns the family and transport byte,
+// unspecified if the addresses do not fit the protocol.
+func encodeHAProxyV2Addrs(body *bytes.Buffer, protocol HAProxyProtocol, src, dst net.Addr) byte {
+	switch protocol {
+	case HAProxyProtocolTCP4, HAProxyProtocolTCP6, HAProxyProtocolUDP4, HAProxyProtocolUDP6:
+		srcIP, srcPort := addrIPPort(src)
+		dstIP, dstPort := addrIPPort(dst)
+
+		familyTransport, ipLength := byte(0x11), net.IPv4len
+		switch protocol {
+		case HAProxyProtocolTCP6:
+			familyTransport, ipLength = 0x21, net.IPv6len
+		case HAProxyProtocolUDP4:
+			familyTransport = 0x12
+		case HAProxyProtocolUDP6:
+			familyTransport, ipLength = 0x22, net.IPv6len
+		}
+
+		if ipLength == net.IPv4len {
+			srcIP, dstIP = srcIP.To4(), dstIP.To4()
+		} else {
+			srcIP, dstIP = srcIP.To16(), dstIP.To16()
+		}
+
+		if srcIP == nil || dstIP == nil {
+			return 0
+		}
+
+		body.Write(srcIP)
+		body.Write(dstIP)
+		_ = binary.Write(body, binary.BigEndian, uint16(srcPort))
+		_ = binary.Write(body, binary.BigEndian, uint16(dstPort))
+
+		return familyTransport
+	case HAProxyProtocolUnixStream, HAProxyProtocolUnixDgram:
+		srcAddr, srcOK := src.(*net.UnixAddr)
+		dstAddr, dstOK := dst.(*net.UnixAddr)
+		if !srcOK || !dstOK {
+			return 0
+		}
+
+		path := make([]byte, 2*haproxyUnixAddrLength)
+		copy(path[:haproxyUnixAddrLength], srcAddr.Name)
+		copy(path[haproxyUnixAddrLength:], dstAddr.Name)
+		body.Write(path)
+
+		if protocol == HAProxyProtocolUnixDgram {
+			return 0x32
+		}
+
+		return 0x31
+	}
+
+	return 0
+}
+
+func addrIPPort(addr net.Addr) (net.IP, int) {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP, a.Port
+	case *net.UDPAddr:
+		return a.IP, a.Port
+	}
+
+	return nil, 0
+}
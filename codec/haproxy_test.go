@@ -0,0 +1,92 @@
+package codec
+
+import (
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+)
+
+func TestHAProxyMessageDecoderV1(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(NewByteToMessageDecoderAdapter(NewHAProxyMessageDecoder()))
+
+	if ch.WriteInbound(newInboundBuf([]byte("PROXY TCP4 192.0.2.1 "))) {
+		t.Fatal("expected the partial header to be buffered")
+	}
+
+	ch.WriteInbound(newInboundBuf([]byte("192.0.2.2 56324 443\r\nhello")))
+
+	msg, ok := ch.ReadInbound().(*HAProxyMessage)
+	if !ok {
+		t.Fatal("expected a decoded header")
+	}
+
+	if msg.Version != 1 || msg.Protocol != HAProxyProtocolTCP4 || msg.Source.String() != "192.0.2.1:56324" || msg.Destination.String() != "192.0.2.2:443" {
+		t.Fatalf("unexpected header %+v", msg)
+	}
+
+	if addr := RealRemoteAddress(ch); addr != msg.Source {
+		t.Fatalf("expected the real remote address %v, got %v", msg.Source, addr)
+	}
+
+	readFrame(t, ch, "hello")
+
+	// the decoder removed itself
+	ch.WriteInbound(newInboundBuf([]byte("PROXY")))
+	readFrame(t, ch, "PROXY")
+
+	if err := ch.CheckError(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHAProxyMessageV2(t *testing.T) {
+	sent := &HAProxyMessage{
+		Version:     2,
+		Command:     HAProxyCommandProxy,
+		Protocol:    HAProxyProtocolTCP6,
+		Source:      &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324},
+		Destination: &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443},
+		TLVs: []HAProxyTLV{
+			{Type: HAProxyTLVTypeUniqueID, Value: []byte("request-1")},
+			{Type: HAProxyTLVTypeSSL, Value: append([]byte{HAProxySSLClientSSL, 0, 0, 0, 0}, HAProxyTLVTypeSSLVersion, 0, 7, 'T', 'L', 'S', 'v', '1', '.', '3')},
+		},
+	}
+
+	encoded := NewHAProxyMessageEncoder().Encode(nil, sent)[0].(buffer.ByteBuffer)
+
+	ch := channel.NewEmbeddedChannel(NewByteToMessageDecoderAdapter(NewHAProxyMessageDecoder()))
+	ch.WriteInbound(encoded)
+
+	msg, ok := ch.ReadInbound().(*HAProxyMessage)
+	if !ok {
+		t.Fatalf("expected a decoded header, got error %v", ch.CheckError())
+	}
+
+	if msg.Version != 2 || msg.Protocol != HAProxyProtocolTCP6 || msg.Source.String() != "[2001:db8::1]:56324" || msg.Destination.String() != "[2001:db8::2]:443" {
+		t.Fatalf("unexpected header %+v", msg)
+	}
+
+	if msg.UniqueID() != "request-1" {
+		t.Fatalf("expected unique id request-1, got %q", msg.UniqueID())
+	}
+
+	ssl, ok := msg.SSL()
+	if !ok || ssl.Client != HAProxySSLClientSSL || ssl.SSLVersion() != "TLSv1.3" {
+		t.Fatalf("unexpected SSL TLV %+v", ssl)
+	}
+}
+
+func TestHAProxyMessageDecoderInvalid(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(NewByteToMessageDecoderAdapter(NewHAProxyMessageDecoder()))
+
+	ch.WriteInbound(newInboundBuf([]byte("GET / HTTP/1.1\r\n")))
+
+	if err := ch.CheckError(); err != ErrHAProxyNoHeader {
+		t.Fatalf("expected ErrHAProxyNoHeader, got %v", err)
+	}
+
+	if ch.IsActive() {
+		t.Fatal("expected the channel to be closed")
+	}
+}
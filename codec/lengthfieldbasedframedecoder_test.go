@@ -0,0 +1,50 @@
+package codec
+
+import (
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+)
+
+func readFrame(t *testing.T, ch *channel.EmbeddedChannel, expected string) {
+	frame, ok := ch.ReadInbound().(buffer.ByteBuffer)
+	if !ok {
+		t.Fatalf("expected frame %q", expected)
+	}
+
+	if actual := string(frame.ReadBytes(frame.ReadableBytes())); actual != expected {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestLengthFieldBasedFrameDecoder(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(NewByteToMessageDecoderAdapter(
+		NewLengthFieldBasedFrameDecoder(buffer.BigEndian, 1024, 0, 2, 0, 2)))
+
+	in := buffer.NewByteBufSize(16)
+	in.WriteInt16(5)
+	in.WriteBytes([]byte("hello"))
+	in.WriteInt16(5)
+	in.WriteBytes([]byte("wo"))
+
+	if !ch.WriteInbound(in) {
+		t.Fatal("expected a decoded frame")
+	}
+
+	readFrame(t, ch, "hello")
+
+	// the second frame is completed by the next read
+	if !ch.WriteInbound(buffer.NewByteBuf([]byte("rld"), 0, 3)) {
+		t.Fatal("expected a decoded frame")
+	}
+
+	readFrame(t, ch, "world")
+
+	if ch.Finish() {
+		t.Fatal("expected no message left")
+	}
+
+	if err := ch.CheckError(); err != nil {
+		t.Fatal(err)
+	}
+}
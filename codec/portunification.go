@@ -0,0 +1,220 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"ngio/buffer"
+	"ngio/channel"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrUnknownProtocol  = errors.New("port unification: unknown protocol")
+	ErrDetectionTimeout = errors.New("port unification: protocol detection timed out")
+)
+
+// the signatures of the PROXY protocol headers
+var (
+	ProxyProtocolV1Signature = []byte("PROXY ")
+	ProxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+type DetectionResult int
+
+const (
+	// DetectNeedMoreData asks for more bytes before deciding
+	DetectNeedMoreData DetectionResult = iota
+	DetectMatched
+	DetectNoMatch
+)
+
+// ProtocolDetector tells whether the first bytes of a connection belong to its protocol.
+// prefix holds every byte received so far and must not be modified.
+type ProtocolDetector interface {
+	Detect(prefix []byte) DetectionResult
+}
+
+type ProtocolDetectorFunc func(prefix []byte) DetectionResult
+
+func (f ProtocolDetectorFunc) Detect(prefix []byte) DetectionResult {
+	return f(prefix)
+}
+
+// PrefixDetector matches connections starting with any of prefixes, like a magic number.
+func PrefixDetector(prefixes ...[]byte) ProtocolDetector {
+	return ProtocolDetectorFunc(func(prefix []byte) DetectionResult {
+		result := DetectNoMatch
+
+		for _, p := range prefixes {
+			if len(prefix) >= len(p) {
+				if bytes.HasPrefix(prefix, p) {
+					return DetectMatched
+				}
+			} else if bytes.HasPrefix(p, prefix) {
+				result = DetectNeedMoreData
+			}
+		}
+
+		return result
+	})
+}
+
+// TLSDetector matches connections starting with a TLS handshake record, like a ClientHello.
+func TLSDetector() ProtocolDetector {
+	return PrefixDetector([]byte{0x16, 0x03})
+}
+
+// HTTPDetector matches connections starting with an HTTP/1.x request line.
+func HTTPDetector() ProtocolDetector {
+	return PrefixDetector(
+		[]byte("GET "), []byte("POST "), []byte("PUT "), []byte("DELETE "), []byte("HEAD "),
+		[]byte("OPTIONS "), []byte("PATCH "), []byte("TRACE "), []byte("CONNECT "))
+}
+
+// ProxyProtocolDetector matches connections starting with a PROXY protocol v1 or v2 header.
+func ProxyProtocolDetector() ProtocolDetector {
+	return PrefixDetector(ProxyProtocolV1Signature, ProxyProtocolV2Signature)
+}
+
+// Protocol is one of the protocols served on a port. Configure sets up its handlers once Detector
+// matched, name is the one of the PortUnificationHandler, which is usually replaced:
+//
+//	pipeline.Replace(name, "http", httpHandler)
+//
+// If Configure leaves it in place, the PortUnificationHandler removes itself afterwards.
+type Protocol struct {
+	Name      string
+	Detector  ProtocolDetector
+	Configure func(pipeline *channel.Pipeline, name string)
+}
+
+// the states of a PortUnificationHandler
+const (
+	detecting int32 = iota
+	detected
+)
+
+// PortUnificationHandler buffers the first bytes of a connection until one of its protocols
+// matches them, then sets up the pipeline of that protocol and passes the buffered bytes on.
+// Protocols are tried in order, a connection matching none, or not sending enough bytes within
+// the detection timeout, is closed.
+//
+//	ch.Pipeline().AddLast("unification", codec.NewPortUnificationHandler(5*time.Second,
+//		codec.Protocol{Name: "tls", Detector: codec.TLSDetector(), Configure: configureTLS},
+//		codec.Protocol{Name: "http", Detector: codec.HTTPDetector(), Configure: configureHTTP}))
+type PortUnificationHandler struct {
+	*ByteToMessageDecoderAdapter
+	protocols []Protocol
+	timeout   time.Duration
+	state     int32
+	removed   bool
+	cancel    func()
+}
+
+// NewPortUnificationHandler creates the handler, a zero timeout waits for the bytes as long as it takes.
+func NewPortUnificationHandler(timeout time.Duration, protocols ...Protocol) *PortUnificationHandler {
+	handler := &PortUnificationHandler{
+		protocols: protocols,
+		timeout:   timeout,
+	}
+
+	handler.ByteToMessageDecoderAdapter = NewByteToMessageDecoderAdapter(handler)
+	return handler
+}
+
+func (handler *PortUnificationHandler) HandlerAdded(ctx *channel.Context) {
+	if ctx.Pipeline().Channel().IsActive() {
+		handler.startTimer(ctx)
+	}
+}
+
+func (handler *PortUnificationHandler) HandlerRemoved(ctx *channel.Context) {
+	handler.removed = true
+	handler.stopTimer()
+}
+
+func (handler *PortUnificationHandler) ChannelActive(ctx *channel.Context) {
+	handler.startTimer(ctx)
+	ctx.FireActiveHandler()
+}
+
+func (handler *PortUnificationHandler) ChannelInActive(ctx *channel.Context) {
+	handler.stopTimer()
+	ctx.FireInActiveHandler()
+}
+
+func (handler *PortUnificationHandler) Decode(ctx *channel.Context, in buffer.ByteBuffer) interface{} {
+	if atomic.LoadInt32(&handler.state) != detecting {
+		return nil
+	}
+
+	prefix := in.GetBytes(in.ReaderIndex(), in.ReadableBytes())
+
+	var matched *Protocol
+	result := DetectNoMatch
+
+	for i := range handler.protocols {
+		r := handler.protocols[i].Detector.Detect(prefix)
+		if r == DetectMatched {
+			matched = &handler.protocols[i]
+			break
+		}
+
+		if r == DetectNeedMoreData {
+			result = DetectNeedMoreData
+		}
+	}
+
+	if matched == nil && result == DetectNeedMoreData {
+		return nil
+	}
+
+	// the timer may have closed the channel meanwhile
+	if !atomic.CompareAndSwapInt32(&handler.state, detecting, detected) {
+		return nil
+	}
+
+	handler.stopTimer()
+
+	if matched == nil {
+		in.Skip(in.ReadableBytes())
+		ctx.FireChannelErrorHandler(ErrUnknownProtocol)
+		ctx.Pipeline().Channel().Close()
+		return nil
+	}
+
+	n := in.ReadableBytes()
+	b := make([]byte, n)
+	copy(b, in.ReadBytes(n))
+
+	matched.Configure(ctx.Pipeline(), ctx.Name())
+	if !handler.removed {
+		ctx.Pipeline().Remove(ctx.Name())
+	}
+
+	// the handlers of the protocol now follow the previous context
+	ctx.Prev().FireReadHandler(buffer.NewByteBuf(b, 0, n))
+
+	return nil
+}
+
+func (handler *PortUnificationHandler) startTimer(ctx *channel.Context) {
+	if handler.timeout <= 0 || handler.cancel != nil {
+		return
+	}
+
+	ch := ctx.Pipeline().Channel()
+	handler.cancel = channel.SchedulerOf(ch).Schedule(handler.timeout, func() {
+		if atomic.CompareAndSwapInt32(&handler.state, detecting, detected) {
+			ctx.FireChannelErrorHandler(ErrDetectionTimeout)
+			ch.Close()
+		}
+	})
+}
+
+func (handler *PortUnificationHandler) stopTimer() {
+	if handler.cancel != nil {
+		handler.cancel()
+	}
+}
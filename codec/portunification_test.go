@@ -0,0 +1,87 @@
+package codec
+
+import (
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+	"time"
+)
+
+// newInboundBuf returns a buffer with room for the next reads, like the ones channels read into.
+func newInboundBuf(b []byte) buffer.ByteBuffer {
+	bf := buffer.NewByteBufSize(64)
+	bf.WriteBytes(b)
+	return bf
+}
+
+func newUnificationChannel() *channel.EmbeddedChannel {
+	return channel.NewEmbeddedChannel(NewPortUnificationHandler(time.Second,
+		Protocol{Name: "tls", Detector: TLSDetector(), Configure: func(pipeline *channel.Pipeline, name string) {
+			pipeline.Replace(name, "tls", NewMessageToMessageDecoderAdapter(stringDecoder{}))
+		}},
+		Protocol{Name: "binary", Detector: PrefixDetector([]byte{0xCA, 0xFE}), Configure: func(pipeline *channel.Pipeline, name string) {
+			pipeline.AddAfter(name, "binary", NewByteToMessageDecoderAdapter(NewLengthFieldBasedFrameDecoder(buffer.BigEndian, 1024, 2, 1, 0, 3)))
+		}}))
+}
+
+func TestPortUnificationHandler(t *testing.T) {
+	ch := newUnificationChannel()
+
+	// one byte matches the magic number so far
+	if ch.WriteInbound(newInboundBuf([]byte{0xCA})) {
+		t.Fatal("expected the byte to be buffered")
+	}
+
+	if !ch.WriteInbound(newInboundBuf([]byte{0xFE, 2, 'o', 'k'})) {
+		t.Fatal("expected a decoded frame")
+	}
+
+	readFrame(t, ch, "ok")
+
+	// the handler removed itself, later bytes go to the protocol directly
+	if !ch.WriteInbound(newInboundBuf([]byte{0xCA, 0xFE, 2, 'h', 'i'})) {
+		t.Fatal("expected a decoded frame")
+	}
+
+	readFrame(t, ch, "hi")
+
+	if err := ch.CheckError(); err != nil {
+		t.Fatal(err)
+	}
+
+	ch = newUnificationChannel()
+
+	if !ch.WriteInbound(newInboundBuf([]byte{0x16, 0x03, 0x01})) {
+		t.Fatal("expected the buffered bytes to be passed on")
+	}
+
+	if msg := ch.ReadInbound(); msg != "\x16\x03\x01" {
+		t.Fatalf("expected the replacing handler to get the bytes, got %q", msg)
+	}
+}
+
+func TestPortUnificationHandlerFailure(t *testing.T) {
+	ch := newUnificationChannel()
+
+	ch.WriteInbound(newInboundBuf([]byte("GET /")))
+	if err := ch.CheckError(); err != ErrUnknownProtocol {
+		t.Fatalf("expected ErrUnknownProtocol, got %v", err)
+	}
+
+	if ch.IsActive() {
+		t.Fatal("expected the channel to be closed")
+	}
+
+	ch = newUnificationChannel()
+
+	ch.WriteInbound(newInboundBuf([]byte{0x16}))
+	ch.AdvanceTimeBy(time.Second)
+
+	if err := ch.CheckError(); err != ErrDetectionTimeout {
+		t.Fatalf("expected ErrDetectionTimeout, got %v", err)
+	}
+
+	if ch.IsActive() {
+		t.Fatal("expected the channel to be closed")
+	}
+}
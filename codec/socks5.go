@@ -0,0 +1,301 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"strconv"
+)
+
+var (
+	ErrSocks5InvalidVersion = errors.New("socks5: invalid version")
+)
+
+const Socks5Version = 0x05
+
+// the authentication methods
+const (
+	Socks5AuthNone         byte = 0x00
+	Socks5AuthPassword     byte = 0x02
+	Socks5AuthNoAcceptable byte = 0xFF
+)
+
+const (
+	Socks5CommandConnect      byte = 0x01
+	Socks5CommandBind         byte = 0x02
+	Socks5CommandUDPAssociate byte = 0x03
+)
+
+// the statuses of a Socks5CommandResponse
+const (
+	Socks5StatusSucceeded           byte = 0x00
+	Socks5StatusFailure             byte = 0x01
+	Socks5StatusForbidden           byte = 0x02
+	Socks5StatusNetworkUnreachable  byte = 0x03
+	Socks5StatusHostUnreachable     byte = 0x04
+	Socks5StatusConnectionRefused   byte = 0x05
+	Socks5StatusTTLExpired          byte = 0x06
+	Socks5StatusCommandUnsupported  byte = 0x07
+	Socks5StatusAddressUnsupported  byte = 0x08
+	Socks5PasswordAuthStatusSuccess byte = 0x00
+	Socks5PasswordAuthStatusFailure byte = 0x01
+)
+
+const (
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+
+	socks5PasswordVersion = 0x01
+)
+
+// Socks5InitialRequest is the greeting of a client, the authentication methods it supports.
+type Socks5InitialRequest struct {
+	AuthMethods []byte
+}
+
+// Socks5InitialResponse is the authentication method the server picked, Socks5AuthNoAcceptable if none.
+type Socks5InitialResponse struct {
+	AuthMethod byte
+}
+
+type Socks5PasswordAuthRequest struct {
+	Username string
+	Password string
+}
+
+type Socks5PasswordAuthResponse struct {
+	Status byte
+}
+
+type Socks5CommandRequest struct {
+	Command byte
+	Host    string
+	Port    uint16
+}
+
+// Address returns the "host:port" the command is about.
+func (req *Socks5CommandRequest) Address() string {
+	return net.JoinHostPort(req.Host, strconv.Itoa(int(req.Port)))
+}
+
+// Socks5CommandResponse is the reply to a command, Host and Port are the address the server bound.
+type Socks5CommandResponse struct {
+	Status byte
+	Host   string
+	Port   uint16
+}
+
+// NewSocks5CommandResponse creates the response of status, bound to addr if it is a *net.TCPAddr or a *net.UDPAddr.
+func NewSocks5CommandResponse(status byte, addr net.Addr) *Socks5CommandResponse {
+	res := &Socks5CommandResponse{Status: status, Host: "0.0.0.0"}
+
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		res.Host, res.Port = a.IP.String(), uint16(a.Port)
+	case *net.UDPAddr:
+		res.Host, res.Port = a.IP.String(), uint16(a.Port)
+	}
+
+	return res
+}
+
+// the messages a Socks5ServerDecoder expects
+const (
+	socks5DecodeInitial = iota
+	socks5DecodeAuthOrCommand
+	socks5DecodeCommand
+	socks5DecodeFinished
+)
+
+// Socks5ServerDecoder decodes the requests of a SOCKS5 client: *Socks5InitialRequest, then
+// *Socks5PasswordAuthRequest if the server picked password authentication, then *Socks5CommandRequest.
+// The bytes after the command are passed on unchanged, usually the decoder is removed before.
+// Together with a Socks5ServerEncoder it makes the pipeline of a SOCKS5 server:
+//
+//	ch.Pipeline().AddLast("decoder", codec.NewByteToMessageDecoderAdapter(codec.NewSocks5ServerDecoder()))
+//	ch.Pipeline().AddLast("encoder", codec.NewMessageToMessageEncoderAdapter(codec.NewSocks5ServerEncoder()))
+//	ch.Pipeline().AddLast("handler", socksServerHandler)
+type Socks5ServerDecoder struct {
+	state int
+}
+
+func NewSocks5ServerDecoder() *Socks5ServerDecoder {
+	return &Socks5ServerDecoder{}
+}
+
+func (decoder *Socks5ServerDecoder) Decode(ctx *channel.Context, in buffer.ByteBuffer) interface{} {
+	if decoder.state == socks5DecodeFinished {
+		return in.ReadSlice(in.ReadableBytes())
+	}
+
+	b := in.GetBytes(in.ReaderIndex(), in.ReadableBytes())
+
+	var msg interface{}
+	var n int
+	var err error
+
+	switch {
+	case decoder.state == socks5DecodeInitial:
+		msg, n, err = decodeSocks5InitialRequest(b)
+	case decoder.state == socks5DecodeAuthOrCommand && b[0] == socks5PasswordVersion:
+		msg, n, err = decodeSocks5PasswordAuthRequest(b)
+	default:
+		msg, n, err = decodeSocks5CommandRequest(b)
+	}
+
+	if err != nil {
+		decoder.state = socks5DecodeFinished
+		in.Skip(in.ReadableBytes())
+		panic(err)
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	in.Skip(n)
+
+	switch msg.(type) {
+	case *Socks5InitialRequest:
+		decoder.state = socks5DecodeAuthOrCommand
+	case *Socks5PasswordAuthRequest:
+		// authenticated once, only the command may follow
+		decoder.state = socks5DecodeCommand
+	case *Socks5CommandRequest:
+		decoder.state = socks5DecodeFinished
+	}
+
+	return msg
+}
+
+func decodeSocks5InitialRequest(b []byte) (interface{}, int, error) {
+	if b[0] != Socks5Version {
+		return nil, 0, ErrSocks5InvalidVersion
+	}
+
+	if len(b) < 2 || len(b) < 2+int(b[1]) {
+		return nil, 0, nil
+	}
+
+	methods := append([]byte(nil), b[2:2+int(b[1])]...)
+	return &Socks5InitialRequest{AuthMethods: methods}, 2 + len(methods), nil
+}
+
+func decodeSocks5PasswordAuthRequest(b []byte) (interface{}, int, error) {
+	if len(b) < 2 || len(b) < 2+int(b[1])+1 {
+		return nil, 0, nil
+	}
+
+	ulen := int(b[1])
+	plen := int(b[2+ulen])
+	n := 3 + ulen + plen
+
+	if len(b) < n {
+		return nil, 0, nil
+	}
+
+	return &Socks5PasswordAuthRequest{Username: string(b[2 : 2+ulen]), Password: string(b[3+ulen : n])}, n, nil
+}
+
+func decodeSocks5CommandRequest(b []byte) (interface{}, int, error) {
+	if b[0] != Socks5Version {
+		return nil, 0, ErrSocks5InvalidVersion
+	}
+
+	if len(b) < 4 {
+		return nil, 0, nil
+	}
+
+	host, port, n, err := ReadSocks5Addr(b[3:])
+	if err != nil || n == 0 {
+		return nil, 0, err
+	}
+
+	return &Socks5CommandRequest{Command: b[1], Host: host, Port: port}, 3 + n, nil
+}
+
+// Socks5ServerEncoder encodes the responses of a SOCKS5 server, other messages are passed on unchanged.
+type Socks5ServerEncoder struct{}
+
+func NewSocks5ServerEncoder() *Socks5ServerEncoder {
+	return &Socks5ServerEncoder{}
+}
+
+func (encoder *Socks5ServerEncoder) Encode(ctx *channel.Context, in interface{}) []interface{} {
+	var b []byte
+
+	switch res := in.(type) {
+	case *Socks5InitialResponse:
+		b = []byte{Socks5Version, res.AuthMethod}
+	case *Socks5PasswordAuthResponse:
+		b = []byte{socks5PasswordVersion, res.Status}
+	case *Socks5CommandResponse:
+		var err error
+		if b, err = AppendSocks5Addr([]byte{Socks5Version, res.Status, 0x00}, res.Host, res.Port); err != nil {
+			panic(err)
+		}
+	default:
+		return []interface{}{in}
+	}
+
+	return []interface{}{buffer.NewByteBuf(b, 0, len(b))}
+}
+
+// AppendSocks5Addr appends the SOCKS5 encoding of host and port to b, an IPv4, IPv6 or domain address.
+func AppendSocks5Addr(b []byte, host string, port uint16) ([]byte, error) {
+	ip := net.ParseIP(host)
+
+	switch {
+	case ip != nil && ip.To4() != nil:
+		b = append(b, socks5AddrIPv4)
+		b = append(b, ip.To4()...)
+	case ip != nil:
+		b = append(b, socks5AddrIPv6)
+		b = append(b, ip.To16()...)
+	default:
+		if len(host) == 0 || len(host) > 255 {
+			return nil, fmt.Errorf("socks5: invalid hostname %q", host)
+		}
+
+		b = append(b, socks5AddrDomain, byte(len(host)))
+		b = append(b, host...)
+	}
+
+	return append(b, byte(port>>8), byte(port)), nil
+}
+
+// ReadSocks5Addr reads a SOCKS5 address off the start of b, n is 0 if b does not hold all of it yet.
+func ReadSocks5Addr(b []byte) (host string, port uint16, n int, err error) {
+	if len(b) < 1 {
+		return "", 0, 0, nil
+	}
+
+	switch b[0] {
+	case socks5AddrIPv4:
+		n = 1 + net.IPv4len
+	case socks5AddrIPv6:
+		n = 1 + net.IPv6len
+	case socks5AddrDomain:
+		if len(b) < 2 {
+			return "", 0, 0, nil
+		}
+		n = 2 + int(b[1])
+	default:
+		return "", 0, 0, fmt.Errorf("socks5: invalid address type %d", b[0])
+	}
+
+	if len(b) < n+2 {
+		return "", 0, 0, nil
+	}
+
+	if b[0] == socks5AddrDomain {
+		host = string(b[2:n])
+	} else {
+		host = net.IP(b[1:n]).String()
+	}
+
+	return host, uint16(b[n])<<8 | uint16(b[n+1]), n + 2, nil
+}
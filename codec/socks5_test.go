@@ -0,0 +1,98 @@
+package codec
+
+import (
+	"bytes"
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"strings"
+	"testing"
+)
+
+func TestSocks5ServerCodec(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(
+		NewByteToMessageDecoderAdapter(NewSocks5ServerDecoder()),
+		NewMessageToMessageEncoderAdapter(NewSocks5ServerEncoder()))
+
+	ch.WriteInbound(newInboundBuf([]byte{5, 2, 0, 2}))
+	if req, ok := ch.ReadInbound().(*Socks5InitialRequest); !ok || !bytes.Equal(req.AuthMethods, []byte{0, 2}) {
+		t.Fatalf("unexpected initial request %+v", req)
+	}
+
+	ch.WriteInbound(newInboundBuf([]byte{1, 4, 'u', 's', 'e', 'r', 2, 'p', 'w'}))
+	if req, ok := ch.ReadInbound().(*Socks5PasswordAuthRequest); !ok || req.Username != "user" || req.Password != "pw" {
+		t.Fatalf("unexpected password auth request %+v", req)
+	}
+
+	// the command is split, the bytes following it are passed on
+	ch.WriteInbound(newInboundBuf([]byte{5, 1, 0, 3, 11, 'e', 'x', 'a', 'm', 'p'}))
+	ch.WriteInbound(newInboundBuf([]byte{'l', 'e', '.', 'c', 'o', 'm', 0, 80, 'G', 'E', 'T'}))
+
+	if req, ok := ch.ReadInbound().(*Socks5CommandRequest); !ok || req.Command != Socks5CommandConnect || req.Address() != "example.com:80" {
+		t.Fatalf("unexpected command request %+v", req)
+	}
+
+	readFrame(t, ch, "GET")
+
+	ch.WriteOutbound(NewSocks5CommandResponse(Socks5StatusSucceeded, &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1080}))
+
+	bf, ok := ch.ReadOutbound().(buffer.ByteBuffer)
+	if !ok {
+		t.Fatal("expected an encoded response")
+	}
+
+	if b := bf.ReadBytes(bf.ReadableBytes()); !bytes.Equal(b, []byte{5, 0, 0, 1, 10, 0, 0, 1, 0x04, 0x38}) {
+		t.Fatalf("unexpected command response %v", b)
+	}
+
+	if err := ch.CheckError(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSocks5ServerDecoderErrors(t *testing.T) {
+	newDecoder := func() *channel.EmbeddedChannel {
+		return channel.NewEmbeddedChannel(NewByteToMessageDecoderAdapter(NewSocks5ServerDecoder()))
+	}
+
+	// a second password request is taken as a command of an invalid version
+	ch := newDecoder()
+	ch.WriteInbound(newInboundBuf([]byte{5, 1, 2}))
+	ch.WriteInbound(newInboundBuf([]byte{1, 1, 'u', 1, 'p'}))
+	ch.WriteInbound(newInboundBuf([]byte{1, 1, 'u', 1, 'p'}))
+
+	if _, ok := ch.ReadInbound().(*Socks5InitialRequest); !ok {
+		t.Fatal("expected the initial request")
+	}
+
+	if _, ok := ch.ReadInbound().(*Socks5PasswordAuthRequest); !ok {
+		t.Fatal("expected the password auth request")
+	}
+
+	if msg := ch.ReadInbound(); msg != nil {
+		t.Fatalf("expected the repeated password auth request to be rejected, got %+v", msg)
+	}
+
+	if err := ch.CheckError(); err != ErrSocks5InvalidVersion {
+		t.Fatalf("expected %v, got %v", ErrSocks5InvalidVersion, err)
+	}
+
+	ch = newDecoder()
+	ch.WriteInbound(newInboundBuf([]byte{4, 1, 0}))
+
+	if err := ch.CheckError(); err != ErrSocks5InvalidVersion {
+		t.Fatalf("expected %v, got %v", ErrSocks5InvalidVersion, err)
+	}
+
+	ch = newDecoder()
+	ch.WriteInbound(newInboundBuf([]byte{5, 1, 0}))
+	ch.WriteInbound(newInboundBuf([]byte{5, 1, 0, 9, 0, 0}))
+
+	if _, ok := ch.ReadInbound().(*Socks5InitialRequest); !ok {
+		t.Fatal("expected the initial request")
+	}
+
+	if err := ch.CheckError(); err == nil || !strings.Contains(err.Error(), "invalid address type") {
+		t.Fatalf("expected an invalid address type, got %v", err)
+	}
+}
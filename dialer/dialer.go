@@ -4,6 +4,7 @@ import "errors"
 
 var (
 	ErrDialAddrIsNil = errors.New("dialer remote addr is nil")
+	ErrConnIsNil     = errors.New("dialer conn is nil")
 )
 
 type Dialer interface {
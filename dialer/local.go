@@ -0,0 +1,60 @@
+package dialer
+
+import (
+	"ngio/channel"
+	"ngio/logger"
+	"ngio/option"
+)
+
+type LocalDialer struct {
+	raddr       *channel.LocalAddr
+	ch          *channel.LocalChannel
+	opts        *option.Options
+	log         logger.Logger
+	initializer channel.Initializer
+}
+
+func NewLocalDialer(raddr string, opts *option.Options, initializer channel.Initializer) (*LocalDialer, error) {
+	if opts == nil {
+		return nil, option.ErrOptionIsNil
+	}
+
+	return &LocalDialer{
+		raddr:       channel.NewLocalAddr(raddr),
+		opts:        opts,
+		log:         logger.DefaultLogger(),
+		initializer: initializer,
+	}, nil
+}
+
+func (dal *LocalDialer) Dial() error {
+	if dal.raddr == nil {
+		return ErrDialAddrIsNil
+	}
+
+	ch, err := channel.DialLocal(dal.raddr)
+	if err != nil {
+		return err
+	}
+
+	dal.log.Infof("[network: %v, local: %v, remote: %v] dialed", ch.RemoteAddress().Network(), ch.LocalAddress(), ch.RemoteAddress())
+
+	dal.ch = ch
+	if dal.initializer != nil {
+		dal.initializer(dal.ch)
+	}
+
+	return dal.ch.Serve()
+}
+
+func (dal *LocalDialer) Close() {
+	if dal.ch == nil {
+		return
+	}
+
+	if !dal.ch.IsActive() {
+		return
+	}
+
+	dal.ch.Close()
+}
@@ -1,22 +1,36 @@
 package dialer
 
 import (
-	"crypto/tls"
 	"net"
 	"ngio/channel"
+	"ngio/handler/proxy"
+	"ngio/handler/ssl"
 	"ngio/logger"
 	"ngio/option"
 )
 
 type TCPDialer struct {
 	laddr, raddr *net.TCPAddr
+	destination  string
+	conn         net.Conn
 	ch           *channel.TCPChannel
 	opts         *option.Options
 	log          logger.Logger
 	initializer  channel.Initializer
 }
 
+// NewTCPDialer creates a dialer connecting to raddr, or to the proxy of option.Proxy which connects to raddr.
 func NewTCPDialer(network, laddr, raddr string, opts *option.Options, initializer channel.Initializer) (*TCPDialer, error) {
+	if opts == nil {
+		return nil, option.ErrOptionIsNil
+	}
+
+	// the proxy resolves the destination
+	destination := ""
+	if opts.Proxy != nil {
+		destination, raddr = raddr, opts.Proxy.Address
+	}
+
 	remoteAddr, err := net.ResolveTCPAddr(network, raddr)
 	if err != nil {
 		return nil, err
@@ -27,43 +41,90 @@ func NewTCPDialer(network, laddr, raddr string, opts *option.Options, initialize
 		return nil, err
 	}
 
-	if opts == nil {
-		return nil, option.ErrOptionIsNil
+	if err := option.ValidateTCPOptions(opts, false); err != nil {
+		return nil, err
 	}
 
 	return &TCPDialer{
 		laddr:       localAddr,
 		raddr:       remoteAddr,
+		destination: destination,
 		opts:        opts,
 		log:         logger.DefaultLogger(),
 		initializer: initializer,
 	}, nil
 }
 
-func (dal *TCPDialer) Dial() error {
-	if dal.raddr == nil {
-		return ErrDialAddrIsNil
+// FromConn creates a TCPDialer serving an already connected conn instead of dialing,
+// e.g. one tunneled over SSH or one end of net.Pipe. Socket options are only applied to *net.TCPConn.
+func FromConn(conn net.Conn, opts *option.Options, initializer channel.Initializer) (*TCPDialer, error) {
+	if conn == nil {
+		return nil, ErrConnIsNil
 	}
 
-	conn, err := net.DialTCP(dal.raddr.Network(), dal.laddr, dal.raddr)
-	if err != nil {
-		return err
+	if opts == nil {
+		return nil, option.ErrOptionIsNil
+	}
+
+	if err := option.ValidateTCPOptions(opts, false); err != nil {
+		return nil, err
+	}
+
+	return &TCPDialer{
+		conn:        conn,
+		opts:        opts,
+		log:         logger.DefaultLogger(),
+		initializer: initializer,
+	}, nil
+}
+
+func (dal *TCPDialer) Dial() error {
+	conn := dal.conn
+
+	if conn == nil {
+		if dal.raddr == nil {
+			return ErrDialAddrIsNil
+		}
+
+		tcpConn, err := dal.dial()
+		if err != nil {
+			return err
+		}
+
+		dal.log.Infof("[network: %v, local: %v, remote: %v] dialed", tcpConn.RemoteAddr().Network(), tcpConn.LocalAddr(), tcpConn.RemoteAddr())
+
+		conn = tcpConn
 	}
 
-	dal.log.Infof("[network: %v, local: %v, remote: %v] dialed", conn.RemoteAddr().Network(), conn.LocalAddr(), conn.RemoteAddr())
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := option.SetupTCPOptions(tcpConn, dal.opts); err != nil {
+			dal.log.Errorf("[network: %v, local: %v, remote: %v] set socket option\r\n %v", conn.RemoteAddr().Network(), conn.LocalAddr(), conn.RemoteAddr(), err)
+			if closeErr := conn.Close(); closeErr != nil {
+				dal.log.Errorf("[network: %v, local: %v, remote: %v] close\r\n %v", conn.RemoteAddr().Network(), conn.LocalAddr(), conn.RemoteAddr(), closeErr)
+			}
+			return err
+		}
+	}
 
-	if err := option.SetupTCPOptions(conn, dal.opts); err != nil {
-		dal.log.Errorf("[network: %v, local: %v, remote: %v] set socket option\r\n %v", conn.RemoteAddr().Network(), conn.LocalAddr(), conn.RemoteAddr(), err)
-		if closeErr := conn.Close(); closeErr != nil {
-			dal.log.Errorf("[network: %v, local: %v, remote: %v] close\r\n %v", conn.RemoteAddr().Network(), conn.LocalAddr(), conn.RemoteAddr(), closeErr)
+	var proxyHandler *proxy.ProxyHandler
+	if dal.opts.Proxy != nil && dal.destination != "" {
+		var err error
+		if proxyHandler, err = proxy.NewProxyHandler(dal.opts.Proxy, dal.destination); err != nil {
+			if closeErr := conn.Close(); closeErr != nil {
+				dal.log.Errorf("[network: %v, local: %v, remote: %v] close\r\n %v", conn.RemoteAddr().Network(), conn.LocalAddr(), conn.RemoteAddr(), closeErr)
+			}
+			return err
 		}
-		return err
 	}
 
+	dal.ch = channel.NewTCPChannel(conn, dal.opts.WriteDeadlinePeriod, dal.opts.ReadDeadlinePeriod)
 	if dal.opts.TLSConfig != nil {
-		dal.ch = channel.NewTCPChannel(tls.Client(conn, dal.opts.TLSConfig), dal.opts.WriteDeadlinePeriod, dal.opts.ReadDeadlinePeriod)
-	} else {
-		dal.ch = channel.NewTCPChannel(conn, dal.opts.WriteDeadlinePeriod, dal.opts.ReadDeadlinePeriod)
+		dal.ch.Pipeline().AddFirst("ssl", ssl.NewSslHandler(dal.opts.TLSConfig, true, dal.opts.TLSHandshakeTimeout))
+	}
+
+	// the handshake with the destination starts once the proxy connected
+	if proxyHandler != nil {
+		dal.ch.Pipeline().AddFirst("proxy", proxyHandler)
 	}
 
 	if dal.initializer != nil {
@@ -73,6 +134,24 @@ func (dal *TCPDialer) Dial() error {
 	return dal.ch.Serve()
 }
 
+func (dal *TCPDialer) dial() (*net.TCPConn, error) {
+	if !option.NeedDialControl(dal.opts) {
+		return net.DialTCP(dal.raddr.Network(), dal.laddr, dal.raddr)
+	}
+
+	d := net.Dialer{Control: option.DialControl(dal.opts)}
+	if dal.laddr != nil {
+		d.LocalAddr = dal.laddr
+	}
+
+	conn, err := d.Dial(dal.raddr.Network(), dal.raddr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.(*net.TCPConn), nil
+}
+
 func (dal *TCPDialer) Close() {
 	if dal.ch == nil {
 		return
@@ -9,6 +9,7 @@ import (
 
 type UDPDialer struct {
 	laddr, raddr *net.UDPAddr
+	conn         *net.UDPConn
 	ch           *channel.UDPChannel
 	opts         *option.Options
 	log          logger.Logger
@@ -30,6 +31,10 @@ func NewUDPDialer(network, laddr, raddr string, opts *option.Options, initialize
 		return nil, option.ErrOptionIsNil
 	}
 
+	if err := option.ValidateUDPOptions(opts, false); err != nil {
+		return nil, err
+	}
+
 	return &UDPDialer{
 		laddr:       localAddr,
 		raddr:       remoteAddr,
@@ -39,17 +44,45 @@ func NewUDPDialer(network, laddr, raddr string, opts *option.Options, initialize
 	}, nil
 }
 
-func (dal *UDPDialer) Dial() error {
-	if dal.raddr == nil {
-		return ErrDialAddrIsNil
+// FromUDPConn creates a UDPDialer serving an existing conn instead of dialing.
+func FromUDPConn(conn *net.UDPConn, opts *option.Options, initializer channel.Initializer) (*UDPDialer, error) {
+	if conn == nil {
+		return nil, ErrConnIsNil
 	}
 
-	conn, err := net.DialUDP(dal.raddr.Network(), dal.laddr, dal.raddr)
-	if err != nil {
-		return err
+	if opts == nil {
+		return nil, option.ErrOptionIsNil
+	}
+
+	if err := option.ValidateUDPOptions(opts, false); err != nil {
+		return nil, err
 	}
 
-	dal.log.Infof("[network: %v, local: %v, remote: %v] dialed", conn.RemoteAddr().Network(), conn.LocalAddr(), conn.RemoteAddr())
+	return &UDPDialer{
+		conn:        conn,
+		opts:        opts,
+		log:         logger.DefaultLogger(),
+		initializer: initializer,
+	}, nil
+}
+
+func (dal *UDPDialer) Dial() error {
+	conn := dal.conn
+
+	if conn == nil {
+		if dal.raddr == nil {
+			return ErrDialAddrIsNil
+		}
+
+		udpConn, err := net.DialUDP(dal.raddr.Network(), dal.laddr, dal.raddr)
+		if err != nil {
+			return err
+		}
+
+		dal.log.Infof("[network: %v, local: %v, remote: %v] dialed", udpConn.RemoteAddr().Network(), udpConn.LocalAddr(), udpConn.RemoteAddr())
+
+		conn = udpConn
+	}
 
 	if err := option.SetupUDPOptions(conn, dal.opts); err != nil {
 		dal.log.Errorf("[network: %v, local: %v, remote: %v] set socket option\r\n %v", conn.RemoteAddr().Network(), conn.LocalAddr(), conn.RemoteAddr(), err)
@@ -59,7 +92,7 @@ func (dal *UDPDialer) Dial() error {
 		return err
 	}
 
-	dal.ch = channel.NewUDPChannel(conn)
+	dal.ch = channel.NewUDPChannel(conn, dal.opts)
 	if dal.initializer != nil {
 		dal.initializer(dal.ch)
 	}
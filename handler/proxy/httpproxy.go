@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrHTTPResponseTooLarge = errors.New("proxy: http proxy response header exceeds the maximum size")
+)
+
+// the largest response header accepted from an HTTP proxy
+const maxHTTPResponseHeaderSize = 16 * 1024
+
+// HTTPProxyError is the error of an HTTP proxy answering CONNECT with another status than 2xx,
+// like 407 if it requires other credentials.
+type HTTPProxyError struct {
+	Destination string
+	StatusCode  int
+	Status      string
+	Header      http.Header
+}
+
+func (err *HTTPProxyError) Error() string {
+	return fmt.Sprintf("proxy: http connect to %s: %s", err.Destination, err.Status)
+}
+
+// httpConnect tunnels through an HTTP proxy with the CONNECT method.
+type httpConnect struct {
+	destination        string
+	username, password string
+	header             http.Header
+}
+
+// NewHTTPProxyHandler creates the handler connecting to destination, a "host:port", through an HTTP proxy.
+// With username it authenticates with basic auth, header is sent along with the CONNECT request.
+func NewHTTPProxyHandler(destination, username, password string, header http.Header, connectTimeout time.Duration) *ProxyHandler {
+	return newProxyHandler(&httpConnect{destination: destination, username: username, password: password, header: header}, destination, connectTimeout)
+}
+
+func (*httpConnect) name() string {
+	return "http"
+}
+
+func (h *httpConnect) authScheme() string {
+	if h.username != "" {
+		return "basic"
+	}
+
+	return "none"
+}
+
+func (h *httpConnect) request() ([]byte, error) {
+	if _, _, err := splitHostPort(h.destination); err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	for k, v := range h.header {
+		header[k] = v
+	}
+
+	if h.username != "" {
+		header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(h.username+":"+h.password)))
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", h.destination, h.destination)
+	if err := header.Write(&b); err != nil {
+		return nil, err
+	}
+	b.WriteString("\r\n")
+
+	return b.Bytes(), nil
+}
+
+func (h *httpConnect) response(in []byte) (int, []byte, bool, error) {
+	end := bytes.Index(in, []byte("\r\n\r\n"))
+	if end == -1 {
+		if len(in) > maxHTTPResponseHeaderSize {
+			return 0, nil, false, ErrHTTPResponseTooLarge
+		}
+
+		return 0, nil, false, nil
+	}
+
+	n := end + 4
+
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(in[:n])), &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return 0, nil, false, &HTTPProxyError{Destination: h.destination, StatusCode: res.StatusCode, Status: res.Status, Header: res.Header}
+	}
+
+	return n, nil, true, nil
+}
+
+func (*httpConnect) boundAddress() string {
+	return ""
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+	"time"
+)
+
+func TestHTTPProxyHandler(t *testing.T) {
+	recorder := &eventRecorder{}
+	ch := channel.NewEmbeddedChannel(NewHTTPProxyHandler("example.com:443", "user", "secret", http.Header{"X-Tenant": {"blue"}}, time.Second), recorder)
+
+	bf := ch.ReadOutbound().(buffer.ByteBuffer)
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(bf.ReadBytes(bf.ReadableBytes()))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Method != http.MethodConnect || req.Host != "example.com:443" || req.Header.Get("X-Tenant") != "blue" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	if auth := req.Header.Get("Proxy-Authorization"); auth != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Fatalf("unexpected authorization %q", auth)
+	}
+
+	res := []byte("HTTP/1.1 200 Connection established\r\n\r\nhello")
+	ch.WriteInbound(buffer.NewByteBuf(res, 0, len(res)))
+
+	if len(recorder.events) != 1 || recorder.events[0].(ProxyConnectedEvent).AuthScheme != "basic" {
+		t.Fatalf("expected a connected event, got %v", recorder.events)
+	}
+
+	// the bytes of the destination following the response
+	if msg, ok := ch.ReadInbound().(buffer.ByteBuffer); !ok || string(msg.ReadBytes(msg.ReadableBytes())) != "hello" {
+		t.Fatal("expected the bytes after the response")
+	}
+}
+
+func TestHTTPProxyHandlerError(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(NewHTTPProxyHandler("example.com:443", "", "", nil, time.Second))
+
+	res := []byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"proxy\"\r\nContent-Length: 0\r\n\r\n")
+	ch.WriteInbound(buffer.NewByteBuf(res, 0, len(res)))
+
+	err, ok := ch.CheckError().(*HTTPProxyError)
+	if !ok || err.StatusCode != http.StatusProxyAuthRequired || err.Header.Get("Proxy-Authenticate") == "" {
+		t.Fatalf("expected an HTTPProxyError, got %v", err)
+	}
+
+	if ch.IsActive() {
+		t.Fatal("expected the channel to be closed")
+	}
+}
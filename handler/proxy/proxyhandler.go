@@ -0,0 +1,288 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"ngio/logger"
+	"ngio/option"
+	"sync"
+	"time"
+)
+
+var (
+	ErrConnectTimeout = errors.New("proxy: connect timed out")
+	ErrUnsupported    = errors.New("proxy: unsupported proxy protocol")
+)
+
+// ProxyConnectedEvent is fired as user event once the proxy connected to the destination, right before
+// the channel becomes active for the handlers after the ProxyHandler. BoundAddress is the address the
+// proxy reported, the relay of a SOCKS5 UDP ASSOCIATE.
+type ProxyConnectedEvent struct {
+	Protocol     string
+	AuthScheme   string
+	Destination  string
+	BoundAddress string
+}
+
+// ProxyConnectError is the error of a proxy refusing to connect to the destination.
+type ProxyConnectError struct {
+	Protocol    string
+	Destination string
+	Reason      string
+}
+
+func (err *ProxyConnectError) Error() string {
+	return fmt.Sprintf("proxy: %s connect to %s: %s", err.Protocol, err.Destination, err.Reason)
+}
+
+// proxyProtocol is the handshake of a proxy protocol, a sequence of requests and responses.
+type proxyProtocol interface {
+	name() string
+	authScheme() string
+	// request returns the first request
+	request() ([]byte, error)
+	// response consumes a response out of in, n is 0 until it arrived completely. It returns the next
+	// request, if any, and whether the proxy connected.
+	response(in []byte) (n int, next []byte, connected bool, err error)
+	boundAddress() string
+}
+
+// the states of a ProxyHandler
+const (
+	handshaking = iota
+	flushing
+	connected
+	failed
+)
+
+// ProxyHandler connects the channel of a client dialed to a proxy to the destination behind it.
+// It hides the channel from the handlers after it until the proxy connected, then removes itself.
+// Messages written meanwhile are sent once connected. A handshake failing, or not completing within
+// the connect timeout, closes the channel.
+//
+//	ch.Pipeline().AddFirst("proxy", proxy.NewSocks5ProxyHandler("example.com:443", "user", "secret", 10*time.Second))
+type ProxyHandler struct {
+	protocol       proxyProtocol
+	destination    string
+	connectTimeout time.Duration
+	ctx            *channel.Context
+	inbound        []byte
+	pending        []interface{}
+	state          int
+	mu             sync.Mutex
+	startOnce      sync.Once
+	cancel         func()
+	log            logger.Logger
+}
+
+func newProxyHandler(protocol proxyProtocol, destination string, connectTimeout time.Duration) *ProxyHandler {
+	return &ProxyHandler{
+		protocol:       protocol,
+		destination:    destination,
+		connectTimeout: connectTimeout,
+		log:            logger.DefaultLogger(),
+	}
+}
+
+// NewProxyHandler creates the handler connecting to destination through the proxy of config.
+func NewProxyHandler(config *option.ProxyConfig, destination string) (*ProxyHandler, error) {
+	switch config.Protocol {
+	case option.ProxySOCKS5:
+		return NewSocks5ProxyHandler(destination, config.Username, config.Password, config.ConnectTimeout), nil
+	case option.ProxySOCKS4a:
+		return NewSocks4aProxyHandler(destination, config.Username, config.ConnectTimeout), nil
+	case option.ProxyHTTP:
+		return NewHTTPProxyHandler(destination, config.Username, config.Password, config.Header, config.ConnectTimeout), nil
+	}
+
+	return nil, ErrUnsupported
+}
+
+func (handler *ProxyHandler) HandlerAdded(ctx *channel.Context) {
+	handler.ctx = ctx
+
+	if ctx.Pipeline().Channel().IsActive() {
+		handler.start()
+	}
+}
+
+func (handler *ProxyHandler) HandlerRemoved(ctx *channel.Context) {
+	handler.stopTimer()
+}
+
+// ChannelActive starts the handshake, the handlers after this one see the channel active once connected.
+func (handler *ProxyHandler) ChannelActive(ctx *channel.Context) {
+	handler.start()
+}
+
+func (handler *ProxyHandler) ChannelInActive(ctx *channel.Context) {
+	handler.stopTimer()
+	ctx.FireInActiveHandler()
+}
+
+func (handler *ProxyHandler) ChannelRead(ctx *channel.Context, msg interface{}) {
+	bf, ok := msg.(buffer.ByteBuffer)
+	if !ok {
+		ctx.FireReadHandler(msg)
+		return
+	}
+
+	handler.inbound = append(handler.inbound, bf.ReadBytes(bf.ReadableBytes())...)
+
+	for len(handler.inbound) > 0 {
+		n, next, done, err := handler.protocol.response(handler.inbound)
+		if err != nil {
+			handler.fail(err)
+			return
+		}
+
+		if n == 0 {
+			return
+		}
+
+		handler.inbound = handler.inbound[n:]
+
+		if next != nil {
+			handler.writeRequest(next)
+		}
+
+		if done {
+			handler.connect()
+			return
+		}
+	}
+}
+
+func (handler *ProxyHandler) Write(ctx *channel.Context, msg interface{}) {
+	handler.mu.Lock()
+
+	switch handler.state {
+	case handshaking, flushing:
+		handler.pending = append(handler.pending, msg)
+		handler.mu.Unlock()
+	case connected:
+		handler.mu.Unlock()
+		ctx.Write(msg)
+	default:
+		handler.mu.Unlock()
+		handler.log.Warnf("[%v] proxy connect failed, message dropped", ctx.Pipeline().Channel())
+	}
+}
+
+func (handler *ProxyHandler) start() {
+	handler.startOnce.Do(func() {
+		if handler.connectTimeout > 0 {
+			handler.cancel = channel.SchedulerOf(handler.ctx.Pipeline().Channel()).Schedule(handler.connectTimeout, func() {
+				handler.fail(ErrConnectTimeout)
+			})
+		}
+
+		request, err := handler.protocol.request()
+		if err != nil {
+			handler.fail(err)
+			return
+		}
+
+		handler.writeRequest(request)
+	})
+}
+
+func (handler *ProxyHandler) writeRequest(b []byte) {
+	handler.ctx.Write(buffer.NewByteBuf(b, 0, len(b)))
+}
+
+func (handler *ProxyHandler) connect() {
+	ctx := handler.ctx
+	handler.stopTimer()
+
+	handler.mu.Lock()
+	if handler.state != handshaking {
+		handler.mu.Unlock()
+		return
+	}
+
+	handler.state = flushing
+	handler.mu.Unlock()
+
+	// the pending messages go first, written without the lock, messages written meanwhile are
+	// queued behind them until none is left
+	for {
+		handler.mu.Lock()
+		pending := handler.pending
+		handler.pending = nil
+		if len(pending) == 0 {
+			handler.state = connected
+			handler.mu.Unlock()
+			break
+		}
+		handler.mu.Unlock()
+
+		for _, msg := range pending {
+			ctx.Write(msg)
+		}
+	}
+
+	handler.log.Debugf("[%v] %s proxy connected to %s", ctx.Pipeline().Channel(), handler.protocol.name(), handler.destination)
+
+	ctx.Pipeline().Remove(ctx.Name())
+
+	ctx.FireUserEventHandler(ProxyConnectedEvent{
+		Protocol:     handler.protocol.name(),
+		AuthScheme:   handler.protocol.authScheme(),
+		Destination:  handler.destination,
+		BoundAddress: boundAddress(handler.protocol.boundAddress(), ctx.Pipeline().Channel().RemoteAddress()),
+	})
+	ctx.FireActiveHandler()
+
+	// the destination may have sent bytes right after the proxy's response
+	if len(handler.inbound) > 0 {
+		ctx.FireReadHandler(buffer.NewByteBuf(handler.inbound, 0, len(handler.inbound)))
+		handler.inbound = nil
+	}
+}
+
+// boundAddress replaces the unspecified host proxies often report by the one of the proxy.
+func boundAddress(bound string, proxy net.Addr) string {
+	host, port, err := net.SplitHostPort(bound)
+	if err != nil {
+		return bound
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+		return bound
+	}
+
+	if tcpAddr, ok := proxy.(*net.TCPAddr); ok {
+		return net.JoinHostPort(tcpAddr.IP.String(), port)
+	}
+
+	return bound
+}
+
+func (handler *ProxyHandler) fail(err error) {
+	handler.mu.Lock()
+	if handler.state != handshaking {
+		handler.mu.Unlock()
+		return
+	}
+
+	handler.state = failed
+	handler.pending = nil
+	handler.mu.Unlock()
+
+	handler.stopTimer()
+
+	handler.log.Errorf("[%v] %s proxy connect to %s\r\n %v", handler.ctx.Pipeline().Channel(), handler.protocol.name(), handler.destination, err)
+
+	handler.ctx.FireChannelErrorHandler(err)
+	handler.ctx.Pipeline().Channel().Close()
+}
+
+func (handler *ProxyHandler) stopTimer() {
+	if handler.cancel != nil {
+		handler.cancel()
+	}
+}
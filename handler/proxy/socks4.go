@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	socks4Version         = 0x04
+	socks4CommandConnect  = 0x01
+	socks4ReplyVersion    = 0x00
+	socks4ReplyGranted    = 0x5A
+	socks4ReplyLength     = 8
+	socks4MaxHostnameSize = 255
+)
+
+// socks4a talks SOCKS4a, which lets the proxy resolve the hostname of the destination.
+type socks4a struct {
+	destination string
+	userID      string
+}
+
+// NewSocks4aProxyHandler creates the handler connecting to destination, a "host:port", through a SOCKS4a proxy.
+func NewSocks4aProxyHandler(destination, userID string, connectTimeout time.Duration) *ProxyHandler {
+	return newProxyHandler(&socks4a{destination: destination, userID: userID}, destination, connectTimeout)
+}
+
+func (*socks4a) name() string {
+	return "socks4a"
+}
+
+func (*socks4a) authScheme() string {
+	return "none"
+}
+
+func (s *socks4a) request() ([]byte, error) {
+	host, port, err := splitHostPort(s.destination)
+	if err != nil {
+		return nil, err
+	}
+
+	b := []byte{socks4Version, socks4CommandConnect, byte(port >> 8), byte(port)}
+
+	ip := net.ParseIP(host).To4()
+	if ip != nil {
+		b = append(b, ip...)
+	} else {
+		if len(host) > socks4MaxHostnameSize {
+			return nil, fmt.Errorf("proxy: hostname %q is too long", host)
+		}
+
+		// the address 0.0.0.x asks the proxy to resolve the hostname following the user id
+		b = append(b, 0, 0, 0, 1)
+	}
+
+	b = append(b, s.userID...)
+	b = append(b, 0)
+
+	if ip == nil {
+		b = append(b, host...)
+		b = append(b, 0)
+	}
+
+	return b, nil
+}
+
+func (s *socks4a) response(in []byte) (int, []byte, bool, error) {
+	if len(in) < socks4ReplyLength {
+		return 0, nil, false, nil
+	}
+
+	if in[0] != socks4ReplyVersion {
+		return 0, nil, false, fmt.Errorf("proxy: invalid socks4 reply version %d", in[0])
+	}
+
+	if in[1] != socks4ReplyGranted {
+		return 0, nil, false, &ProxyConnectError{Protocol: s.name(), Destination: s.destination, Reason: socks4Reason(in[1])}
+	}
+
+	return socks4ReplyLength, nil, true, nil
+}
+
+func (*socks4a) boundAddress() string {
+	return ""
+}
+
+func socks4Reason(reply byte) string {
+	switch reply {
+	case 0x5B:
+		return "request rejected or failed"
+	case 0x5C:
+		return "identd unreachable"
+	case 0x5D:
+		return "user id rejected by identd"
+	}
+
+	return "reply " + strconv.Itoa(int(reply))
+}
+
+func splitHostPort(address string) (string, uint16, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", 0, errors.New("proxy: invalid port " + strconv.Quote(port))
+	}
+
+	return host, uint16(p), nil
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+	"time"
+)
+
+func TestSocks4aProxyHandler(t *testing.T) {
+	recorder := &eventRecorder{}
+	ch := channel.NewEmbeddedChannel(NewSocks4aProxyHandler("example.com:80", "id", time.Second), recorder)
+
+	// the hostname follows the user id, the proxy resolves it
+	readRequest(t, ch, []byte{4, 1, 0, 80, 0, 0, 0, 1, 'i', 'd', 0, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm', 0})
+
+	// the reply is split, the bytes of the destination follow it
+	ch.WriteInbound(buffer.NewByteBuf([]byte{0, 0x5A, 0, 0}, 0, 4))
+	ch.WriteInbound(buffer.NewByteBuf([]byte{0, 0, 0, 0, 'h', 'i'}, 0, 6))
+
+	if len(recorder.events) != 1 || recorder.events[0].(ProxyConnectedEvent).AuthScheme != "none" {
+		t.Fatalf("expected a connected event, got %v", recorder.events)
+	}
+
+	if msg, ok := ch.ReadInbound().(buffer.ByteBuffer); !ok || string(msg.ReadBytes(msg.ReadableBytes())) != "hi" {
+		t.Fatal("expected the bytes after the reply")
+	}
+
+	if err := ch.CheckError(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSocks4aProxyHandlerAddress(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(NewSocks4aProxyHandler("192.0.2.1:443", "", time.Second))
+
+	readRequest(t, ch, []byte{4, 1, 0x01, 0xBB, 192, 0, 2, 1, 0})
+}
+
+func TestSocks4aProxyHandlerRejected(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		reply      []byte
+		connectErr bool
+	}{
+		{"rejected", []byte{0, 0x5B, 0, 0, 0, 0, 0, 0}, true},
+		{"invalid version", []byte{4, 0x5A, 0, 0, 0, 0, 0, 0}, false},
+	} {
+		ch := channel.NewEmbeddedChannel(NewSocks4aProxyHandler("example.com:80", "", time.Second))
+		ch.ReadOutbound()
+
+		ch.WriteInbound(buffer.NewByteBuf(test.reply, 0, len(test.reply)))
+
+		err := ch.CheckError()
+		if err == nil {
+			t.Fatalf("%s: expected an error", test.name)
+		}
+
+		if connectErr, ok := err.(*ProxyConnectError); test.connectErr && (!ok || connectErr.Protocol != "socks4a") {
+			t.Fatalf("%s: expected a ProxyConnectError, got %v", test.name, err)
+		}
+
+		if ch.IsActive() {
+			t.Fatalf("%s: expected the channel to be closed", test.name)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"ngio/codec"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNoAcceptableAuth = errors.New("proxy: socks5 proxy accepts none of the offered authentication methods")
+	ErrAuthFailed       = errors.New("proxy: socks5 authentication failed")
+)
+
+const socks5PasswordVersion = 0x01
+
+// the steps of a SOCKS5 handshake
+const (
+	socks5Greeting = iota
+	socks5Auth
+	socks5Command
+)
+
+type socks5 struct {
+	command            byte
+	destination        string
+	username, password string
+	step               int
+	auth               byte
+	bound              string
+}
+
+// NewSocks5ProxyHandler creates the handler connecting to destination, a "host:port", through a SOCKS5 proxy.
+// Without username it offers no authentication, otherwise username and password.
+func NewSocks5ProxyHandler(destination, username, password string, connectTimeout time.Duration) *ProxyHandler {
+	return newProxyHandler(&socks5{command: codec.Socks5CommandConnect, destination: destination, username: username, password: password}, destination, connectTimeout)
+}
+
+// NewSocks5UDPAssociateHandler creates the handler asking a SOCKS5 proxy to relay UDP datagrams, the relay
+// address is the BoundAddress of the ProxyConnectedEvent. The relay lasts as long as the channel, datagrams
+// are sent to it through a Socks5DatagramHandler.
+func NewSocks5UDPAssociateHandler(username, password string, connectTimeout time.Duration) *ProxyHandler {
+	return newProxyHandler(&socks5{command: codec.Socks5CommandUDPAssociate, destination: "0.0.0.0:0", username: username, password: password}, "0.0.0.0:0", connectTimeout)
+}
+
+func (*socks5) name() string {
+	return "socks5"
+}
+
+func (s *socks5) authScheme() string {
+	if s.auth == codec.Socks5AuthPassword {
+		return "password"
+	}
+
+	return "none"
+}
+
+func (s *socks5) request() ([]byte, error) {
+	if s.username == "" {
+		return []byte{codec.Socks5Version, 1, codec.Socks5AuthNone}, nil
+	}
+
+	return []byte{codec.Socks5Version, 2, codec.Socks5AuthNone, codec.Socks5AuthPassword}, nil
+}
+
+func (s *socks5) response(in []byte) (int, []byte, bool, error) {
+	switch s.step {
+	case socks5Greeting:
+		return s.greetingResponse(in)
+	case socks5Auth:
+		return s.authResponse(in)
+	default:
+		return s.commandResponse(in)
+	}
+}
+
+func (s *socks5) greetingResponse(in []byte) (int, []byte, bool, error) {
+	if len(in) < 2 {
+		return 0, nil, false, nil
+	}
+
+	if in[0] != codec.Socks5Version {
+		return 0, nil, false, fmt.Errorf("proxy: invalid socks5 version %d", in[0])
+	}
+
+	s.auth = in[1]
+
+	switch {
+	case s.auth == codec.Socks5AuthNone:
+		next, err := s.commandRequest()
+		s.step = socks5Command
+		return 2, next, false, err
+	case s.auth == codec.Socks5AuthPassword && s.username != "":
+		if len(s.username) > 255 || len(s.password) > 255 {
+			return 0, nil, false, errors.New("proxy: socks5 username or password is too long")
+		}
+
+		next := []byte{socks5PasswordVersion, byte(len(s.username))}
+		next = append(next, s.username...)
+		next = append(next, byte(len(s.password)))
+		next = append(next, s.password...)
+
+		s.step = socks5Auth
+		return 2, next, false, nil
+	}
+
+	return 0, nil, false, ErrNoAcceptableAuth
+}
+
+func (s *socks5) authResponse(in []byte) (int, []byte, bool, error) {
+	if len(in) < 2 {
+		return 0, nil, false, nil
+	}
+
+	if in[0] != socks5PasswordVersion {
+		return 0, nil, false, fmt.Errorf("proxy: invalid socks5 password auth version %d", in[0])
+	}
+
+	if in[1] != 0x00 {
+		return 0, nil, false, ErrAuthFailed
+	}
+
+	next, err := s.commandRequest()
+	s.step = socks5Command
+	return 2, next, false, err
+}
+
+func (s *socks5) commandRequest() ([]byte, error) {
+	host, port, err := splitHostPort(s.destination)
+	if err != nil {
+		return nil, err
+	}
+
+	return codec.AppendSocks5Addr([]byte{codec.Socks5Version, s.command, 0x00}, host, port)
+}
+
+func (s *socks5) commandResponse(in []byte) (int, []byte, bool, error) {
+	if len(in) < 4 {
+		return 0, nil, false, nil
+	}
+
+	if in[0] != codec.Socks5Version {
+		return 0, nil, false, fmt.Errorf("proxy: invalid socks5 version %d", in[0])
+	}
+
+	if in[1] != codec.Socks5StatusSucceeded {
+		return 0, nil, false, &ProxyConnectError{Protocol: s.name(), Destination: s.destination, Reason: socks5Reason(in[1])}
+	}
+
+	host, port, n, err := codec.ReadSocks5Addr(in[3:])
+	if err != nil || n == 0 {
+		return 0, nil, false, err
+	}
+
+	s.bound = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	return 3 + n, nil, true, nil
+}
+
+func (s *socks5) boundAddress() string {
+	return s.bound
+}
+
+func socks5Reason(reply byte) string {
+	switch reply {
+	case 0x01:
+		return "general socks server failure"
+	case 0x02:
+		return "connection not allowed by ruleset"
+	case 0x03:
+		return "network unreachable"
+	case 0x04:
+		return "host unreachable"
+	case 0x05:
+		return "connection refused"
+	case 0x06:
+		return "ttl expired"
+	case 0x07:
+		return "command not supported"
+	case 0x08:
+		return "address type not supported"
+	}
+
+	return "reply " + strconv.Itoa(int(reply))
+}
+
+// Socks5DatagramHandler sends the datagrams of a UDP channel connected to the relay of a SOCKS5
+// UDP ASSOCIATE, see NewSocks5UDPAssociateHandler. The *buffer.DatagramPacket written to it are sent to
+// their remote address through the relay, the relayed ones read arrive with the address of their sender.
+//
+//	ngio.NewClient("udp", "", evt.BoundAddress).Channel(func(ch channel.Channel) {
+//		ch.Pipeline().AddLast("socks5", proxy.NewSocks5DatagramHandler())
+//		ch.Pipeline().AddLast("handler", handler)
+//	})
+type Socks5DatagramHandler struct{}
+
+func NewSocks5DatagramHandler() *Socks5DatagramHandler {
+	return &Socks5DatagramHandler{}
+}
+
+func (handler *Socks5DatagramHandler) ChannelRead(ctx *channel.Context, msg interface{}) {
+	packet, ok := msg.(*buffer.DatagramPacket)
+	if !ok {
+		ctx.FireReadHandler(msg)
+		return
+	}
+
+	bf := packet.ByteBuf()
+	b := bf.GetBytes(bf.ReaderIndex(), bf.ReadableBytes())
+
+	// fragments are not supported, like by most relays
+	if len(b) < 3 || b[2] != 0x00 {
+		return
+	}
+
+	host, port, n, err := codec.ReadSocks5Addr(b[3:])
+	if err != nil || n == 0 {
+		return
+	}
+
+	// relays send datagrams of IP addresses only
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return
+	}
+
+	bf.Skip(3 + n)
+	ctx.FireReadHandler(buffer.NewAddressedDatagramPacket(packet.LocalAddress(), &net.UDPAddr{IP: ip, Port: int(port)}, bf))
+}
+
+func (handler *Socks5DatagramHandler) Write(ctx *channel.Context, msg interface{}) {
+	packet, ok := msg.(*buffer.DatagramPacket)
+	if !ok || packet.RemoteAddress() == nil {
+		ctx.Write(msg)
+		return
+	}
+
+	// an address without IP is no hostname, AppendSocks5Addr rejects it
+	var host string
+	if raddr := packet.RemoteAddress(); raddr.IP != nil {
+		host = raddr.IP.String()
+	}
+
+	b, err := codec.AppendSocks5Addr([]byte{0x00, 0x00, 0x00}, host, uint16(packet.RemoteAddress().Port))
+	if err != nil {
+		ctx.FireChannelErrorHandler(err)
+		return
+	}
+
+	b = append(b, packet.ByteBuf().ReadBytes(packet.ByteBuf().ReadableBytes())...)
+	ctx.Write(buffer.NewByteBuf(b, 0, len(b)))
+}
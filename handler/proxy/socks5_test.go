@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+	"time"
+)
+
+type eventRecorder struct {
+	events []interface{}
+}
+
+func (recorder *eventRecorder) UserEventTriggered(ctx *channel.Context, evt interface{}) {
+	recorder.events = append(recorder.events, evt)
+}
+
+func readRequest(t *testing.T, ch *channel.EmbeddedChannel, expected []byte) {
+	bf, ok := ch.ReadOutbound().(buffer.ByteBuffer)
+	if !ok {
+		t.Fatalf("expected request %v", expected)
+	}
+
+	if actual := bf.ReadBytes(bf.ReadableBytes()); !bytes.Equal(actual, expected) {
+		t.Fatalf("expected request %v, got %v", expected, actual)
+	}
+}
+
+func TestSocks5UDPAssociate(t *testing.T) {
+	recorder := &eventRecorder{}
+	ch := channel.NewEmbeddedChannel(NewSocks5UDPAssociateHandler("user", "secret", time.Second), recorder)
+
+	readRequest(t, ch, []byte{5, 2, 0, 2})
+	ch.WriteInbound(buffer.NewByteBuf([]byte{5, 2}, 0, 2))
+
+	readRequest(t, ch, []byte{1, 4, 'u', 's', 'e', 'r', 6, 's', 'e', 'c', 'r', 'e', 't'})
+	ch.WriteInbound(buffer.NewByteBuf([]byte{1, 0}, 0, 2))
+
+	readRequest(t, ch, []byte{5, 3, 0, 1, 0, 0, 0, 0, 0, 0})
+	ch.WriteInbound(buffer.NewByteBuf([]byte{5, 0, 0, 1, 10, 0, 0, 1, 0x0F, 0xA0}, 0, 10))
+
+	if len(recorder.events) != 1 {
+		t.Fatalf("expected a connected event, got %v", recorder.events)
+	}
+
+	evt := recorder.events[0].(ProxyConnectedEvent)
+	if evt.AuthScheme != "password" || evt.BoundAddress != "10.0.0.1:4000" {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+
+	// the timer was stopped
+	ch.AdvanceTimeBy(time.Second)
+
+	if err := ch.CheckError(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSocks5ProxyHandlerPassword(t *testing.T) {
+	recorder := &eventRecorder{}
+	ch := channel.NewEmbeddedChannel(NewSocks5ProxyHandler("example.com:80", "user", "secret", time.Second), recorder)
+
+	readRequest(t, ch, []byte{5, 2, 0, 2})
+	ch.WriteInbound(buffer.NewByteBuf([]byte{5, 2}, 0, 2))
+
+	readRequest(t, ch, []byte{1, 4, 'u', 's', 'e', 'r', 6, 's', 'e', 'c', 'r', 'e', 't'})
+	ch.WriteInbound(buffer.NewByteBuf([]byte{1, 0}, 0, 2))
+
+	readRequest(t, ch, []byte{5, 1, 0, 3, 11, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm', 0, 80})
+	ch.WriteInbound(buffer.NewByteBuf([]byte{5, 0, 0, 1, 10, 0, 0, 1, 0x0F, 0xA0}, 0, 10))
+
+	if len(recorder.events) != 1 || recorder.events[0].(ProxyConnectedEvent).AuthScheme != "password" {
+		t.Fatalf("expected a connected event, got %v", recorder.events)
+	}
+
+	if err := ch.CheckError(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSocks5ProxyHandlerAuthVersion(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(NewSocks5ProxyHandler("example.com:80", "user", "secret", time.Second))
+
+	readRequest(t, ch, []byte{5, 2, 0, 2})
+	ch.WriteInbound(buffer.NewByteBuf([]byte{5, 2}, 0, 2))
+
+	readRequest(t, ch, []byte{1, 4, 'u', 's', 'e', 'r', 6, 's', 'e', 'c', 'r', 'e', 't'})
+
+	// only version 1 of the sub-negotiation exists
+	ch.WriteInbound(buffer.NewByteBuf([]byte{5, 0}, 0, 2))
+
+	if err := ch.CheckError(); err == nil {
+		t.Fatal("expected the auth response to be rejected")
+	}
+
+	if ch.IsActive() {
+		t.Fatal("expected the channel to be closed")
+	}
+}
+
+func TestSocks5DatagramHandler(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(NewSocks5DatagramHandler())
+
+	target := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 53}
+	ch.WriteOutbound(buffer.NewDatagramPacket(target, buffer.NewByteBuf([]byte("query"), 0, 5)))
+
+	relayed := []byte{0, 0, 0, 1, 192, 0, 2, 1, 0, 53, 'q', 'u', 'e', 'r', 'y'}
+	readRequest(t, ch, relayed)
+
+	ch.WriteInbound(buffer.NewDatagramPacket(nil, buffer.NewByteBuf(relayed, 0, len(relayed))))
+
+	packet, ok := ch.ReadInbound().(*buffer.DatagramPacket)
+	if !ok {
+		t.Fatal("expected a datagram")
+	}
+
+	if packet.RemoteAddress().String() != target.String() {
+		t.Fatalf("expected the datagram from %v, got %v", target, packet.RemoteAddress())
+	}
+
+	if payload := string(packet.ByteBuf().ReadBytes(packet.ByteBuf().ReadableBytes())); payload != "query" {
+		t.Fatalf("expected query, got %q", payload)
+	}
+}
+
+func TestProxyHandlerTimeout(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(NewSocks5ProxyHandler("example.com:80", "", "", time.Second))
+
+	readRequest(t, ch, []byte{5, 1, 0})
+	ch.AdvanceTimeBy(time.Second)
+
+	if err := ch.CheckError(); err != ErrConnectTimeout {
+		t.Fatalf("expected ErrConnectTimeout, got %v", err)
+	}
+
+	if ch.IsActive() {
+		t.Fatal("expected the channel to be closed")
+	}
+}
+
+func TestSocks5DatagramHandlerInvalidAddress(t *testing.T) {
+	ch := channel.NewEmbeddedChannel(NewSocks5DatagramHandler())
+
+	if ch.WriteOutbound(buffer.NewDatagramPacket(&net.UDPAddr{Port: 53}, buffer.NewByteBuf([]byte("query"), 0, 5))) {
+		t.Fatal("expected the datagram to be dropped")
+	}
+
+	if err := ch.CheckError(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// reentrantWriter writes once more through the whole pipeline when the first message passes,
+// as a handler reacting to a write may.
+type reentrantWriter struct {
+	written bool
+}
+
+func (writer *reentrantWriter) Write(ctx *channel.Context, msg interface{}) {
+	if !writer.written {
+		writer.written = true
+		ctx.Pipeline().FireWriteHandler(buffer.NewByteBuf([]byte("second"), 0, 6))
+	}
+
+	ctx.Write(msg)
+}
+
+func TestProxyHandlerPendingWrites(t *testing.T) {
+	writer := &reentrantWriter{written: true}
+	ch := channel.NewEmbeddedChannel(writer, NewSocks5ProxyHandler("example.com:80", "", "", time.Second))
+
+	readRequest(t, ch, []byte{5, 1, 0})
+
+	ch.WriteOutbound(buffer.NewByteBuf([]byte("first"), 0, 5))
+	if ch.ReadOutbound() != nil {
+		t.Fatal("expected the message to wait for the proxy")
+	}
+
+	ch.WriteInbound(buffer.NewByteBuf([]byte{5, 0}, 0, 2))
+	readRequest(t, ch, append([]byte{5, 1, 0, 3, 11}, append([]byte("example.com"), 0, 80)...))
+
+	// flushing the pending message writes another one, which is sent after it
+	writer.written = false
+	ch.WriteInbound(buffer.NewByteBuf([]byte{5, 0, 0, 1, 10, 0, 0, 1, 0x0F, 0xA0}, 0, 10))
+	readRequest(t, ch, []byte("first"))
+	readRequest(t, ch, []byte("second"))
+
+	if ch.ReadOutbound() != nil {
+		t.Fatal("expected no more messages")
+	}
+
+	if err := ch.CheckError(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,198 @@
+package relay
+
+import (
+	"ngio"
+	"ngio/buffer"
+	"ngio/channel"
+	"ngio/option"
+	"sync"
+	"sync/atomic"
+)
+
+// RelayHandler writes every message read on its channel to the peer channel, and closes the peer
+// once its channel is closed. A relay is a pair of them, one in each channel.
+//
+// Channels allowing half closure shut down the output of the peer once their input is shut down,
+// and are closed once both directions are. Flow controlled channels stop reading while the peer
+// is not writable, so a slow side does not make the other queue without bounds.
+type RelayHandler struct {
+	relayed uint64
+	peer    channel.Channel
+}
+
+func NewRelayHandler(peer channel.Channel) *RelayHandler {
+	return &RelayHandler{
+		peer: peer,
+	}
+}
+
+// Relayed returns how many bytes were written to the peer.
+func (handler *RelayHandler) Relayed() uint64 {
+	return atomic.LoadUint64(&handler.relayed)
+}
+
+func (handler *RelayHandler) HandlerAdded(ctx *channel.Context) {
+	if ch, ok := ctx.Pipeline().Channel().(channel.HalfClosable); ok {
+		ch.SetAllowHalfClosure(true)
+	}
+}
+
+func (handler *RelayHandler) ChannelRead(ctx *channel.Context, msg interface{}) {
+	if buf, ok := msg.(buffer.ByteBuffer); ok {
+		atomic.AddUint64(&handler.relayed, uint64(buf.ReadableBytes()))
+	}
+
+	handler.peer.Write(msg)
+}
+
+func (handler *RelayHandler) UserEventTriggered(ctx *channel.Context, evt interface{}) {
+	switch evt := evt.(type) {
+	case channel.WritabilityChangedEvent:
+		// the peer is what fills this channel
+		if peer, ok := handler.peer.(channel.FlowControlled); ok {
+			peer.SetAutoRead(evt.Writable)
+		}
+	case channel.ChannelInputShutdownEvent:
+		if peer, ok := handler.peer.(channel.HalfClosable); ok {
+			peer.CloseWrite()
+		} else {
+			handler.peer.Close()
+		}
+	}
+
+	ctx.FireUserEventHandler(evt)
+}
+
+func (handler *RelayHandler) ChannelInActive(ctx *channel.Context) {
+	if handler.peer.IsActive() {
+		handler.peer.Close()
+	}
+
+	ctx.FireInActiveHandler()
+}
+
+// Relay is a pair of relay handlers, between the inbound channel, e.g. accepted by a proxy server,
+// and the outbound channel connected on its behalf.
+type Relay struct {
+	inbound, outbound channel.Channel
+	up, down          *RelayHandler
+}
+
+func (relay *Relay) Inbound() channel.Channel {
+	return relay.inbound
+}
+
+func (relay *Relay) Outbound() channel.Channel {
+	return relay.outbound
+}
+
+// Upstream returns how many bytes were relayed from the inbound channel to the outbound one.
+func (relay *Relay) Upstream() uint64 {
+	return relay.up.Relayed()
+}
+
+// Downstream returns how many bytes were relayed from the outbound channel to the inbound one.
+func (relay *Relay) Downstream() uint64 {
+	return relay.down.Relayed()
+}
+
+// Pair relays between inbound and outbound, the handlers are added last to their pipelines.
+func Pair(inbound, outbound channel.Channel) *Relay {
+	relay := &Relay{
+		inbound:  inbound,
+		outbound: outbound,
+		up:       NewRelayHandler(outbound),
+		down:     NewRelayHandler(inbound),
+	}
+
+	inbound.Pipeline().AddLast("relay", relay.up)
+	outbound.Pipeline().AddLast("relay", relay.down)
+
+	return relay
+}
+
+// Dial connects a client to address, then relays between ch and it. connected is called once the client
+// is active, or with the error of the dial. Bytes read on ch are relayed from then on, the handlers before
+// the relay pass them on or are removed by connected. Bytes of the client are relayed once connected returned,
+// so the reply of a proxy server comes first:
+//
+//	relay.Dial(ch, "tcp", req.Address(), nil, func(r *relay.Relay, err error) {
+//		if err != nil {
+//			ctx.Write(codec.NewSocks5CommandResponse(codec.Socks5StatusHostUnreachable, nil))
+//			ch.Close()
+//			return
+//		}
+//
+//		ctx.Write(codec.NewSocks5CommandResponse(codec.Socks5StatusSucceeded, r.Outbound().LocalAddress()))
+//		ch.Pipeline().Remove("decoder")
+//		ch.Pipeline().Remove("encoder")
+//		ch.Pipeline().Remove("handler")
+//	})
+func Dial(ch channel.Channel, network, address string, opts []option.Option, connected func(relay *Relay, err error)) {
+	handler := &connectedHandler{
+		connected: connected,
+		readyC:    make(chan struct{}),
+		relay: &Relay{
+			inbound: ch,
+			up:      NewRelayHandler(nil),
+			down:    NewRelayHandler(ch),
+		},
+	}
+	handler.RelayHandler = handler.relay.down
+
+	client := ngio.NewClient(network, "", address).Option(opts...).Channel(func(peer channel.Channel) {
+		handler.relay.outbound = peer
+		handler.relay.up.peer = peer
+		peer.Pipeline().AddLast("relay", handler)
+	})
+
+	go func() {
+		// the client's channel is served until it is closed
+		if err := client.Dial(); err != nil {
+			handler.once.Do(func() {
+				connected(nil, err)
+			})
+		}
+	}()
+}
+
+// connectedHandler is the RelayHandler of the client, it relays once connected returned.
+type connectedHandler struct {
+	*RelayHandler
+	relay     *Relay
+	connected func(relay *Relay, err error)
+	once      sync.Once
+	readyC    chan struct{}
+}
+
+func (handler *connectedHandler) ChannelActive(ctx *channel.Context) {
+	ch := handler.relay.inbound
+
+	ch.Pipeline().AddLast("relay", handler.relay.up)
+
+	handler.once.Do(func() {
+		handler.connected(handler.relay, nil)
+	})
+	close(handler.readyC)
+
+	if !ch.IsActive() {
+		ctx.Pipeline().Channel().Close()
+		return
+	}
+
+	ctx.FireActiveHandler()
+}
+
+func (handler *connectedHandler) ChannelRead(ctx *channel.Context, msg interface{}) {
+	<-handler.readyC
+	handler.RelayHandler.ChannelRead(ctx, msg)
+}
+
+func (handler *connectedHandler) UserEventTriggered(ctx *channel.Context, evt interface{}) {
+	// no shutdown of the inbound output before the reply of connected
+	if _, ok := evt.(channel.ChannelInputShutdownEvent); ok {
+		<-handler.readyC
+	}
+
+	handler.RelayHandler.UserEventTriggered(ctx, evt)
+}
@@ -0,0 +1,182 @@
+package relay
+
+import (
+	"io"
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+	"time"
+)
+
+// flowControlledChannel records the flow control of the relay.
+type flowControlledChannel struct {
+	*channel.EmbeddedChannel
+	autoRead   bool
+	closeWrite bool
+}
+
+func (ch *flowControlledChannel) SetAutoRead(autoRead bool) { ch.autoRead = autoRead }
+func (ch *flowControlledChannel) IsAutoRead() bool          { return ch.autoRead }
+func (ch *flowControlledChannel) IsWritable() bool          { return true }
+func (ch *flowControlledChannel) SetAllowHalfClosure(bool)  {}
+func (ch *flowControlledChannel) CloseWrite()               { ch.closeWrite = true }
+
+func TestRelay(t *testing.T) {
+	inbound := &flowControlledChannel{EmbeddedChannel: channel.NewEmbeddedChannel(), autoRead: true}
+	outbound := &flowControlledChannel{EmbeddedChannel: channel.NewEmbeddedChannel(), autoRead: true}
+
+	relay := Pair(inbound, outbound)
+
+	inbound.WriteInbound(buffer.NewByteBuf([]byte("hello"), 0, 5))
+	outbound.WriteInbound(buffer.NewByteBuf([]byte("hi"), 0, 2))
+
+	if bf, ok := outbound.ReadOutbound().(buffer.ByteBuffer); !ok || string(bf.ReadBytes(bf.ReadableBytes())) != "hello" {
+		t.Fatal("expected the bytes of inbound written to outbound")
+	}
+
+	if relay.Upstream() != 5 || relay.Downstream() != 2 {
+		t.Fatalf("unexpected counters %d/%d", relay.Upstream(), relay.Downstream())
+	}
+
+	// inbound stops reading while outbound is not writable
+	outbound.Pipeline().FireUserEventHandler(channel.WritabilityChangedEvent{Writable: false})
+	if inbound.autoRead {
+		t.Fatal("expected inbound to stop reading")
+	}
+
+	outbound.Pipeline().FireUserEventHandler(channel.WritabilityChangedEvent{Writable: true})
+	if !inbound.autoRead {
+		t.Fatal("expected inbound to read again")
+	}
+
+	inbound.Pipeline().FireUserEventHandler(channel.ChannelInputShutdownEvent{})
+	if !outbound.closeWrite || !outbound.IsActive() {
+		t.Fatal("expected the output of outbound to be shut down")
+	}
+
+	outbound.Close()
+	if inbound.IsActive() {
+		t.Fatal("expected inbound to be closed with outbound")
+	}
+}
+
+// backend writes a greeting to every connection, then echoes it.
+func backend(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				_, _ = conn.Write([]byte("welcome"))
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return l
+}
+
+func TestDial(t *testing.T) {
+	l := backend(t)
+	defer l.Close()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	inbound := channel.NewTCPChannel(serverConn, 0, 0)
+	go func() {
+		_ = inbound.Serve()
+	}()
+
+	relayC := make(chan *Relay, 1)
+	Dial(inbound, "tcp", l.Addr().String(), nil, func(r *Relay, err error) {
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		// the reply to the client comes before any byte of the backend
+		r.Inbound().Write(buffer.NewByteBuf([]byte("connected"), 0, 9))
+		relayC <- r
+	})
+
+	var r *Relay
+	select {
+	case r = <-relayC:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the connection")
+	}
+
+	_ = clientConn.SetDeadline(time.Now().Add(time.Second))
+
+	expectRead := func(expected string) {
+		b := make([]byte, len(expected))
+		if _, err := io.ReadFull(clientConn, b); err != nil || string(b) != expected {
+			t.Fatalf("expected %v, got %q, %v", expected, b, err)
+		}
+	}
+
+	expectRead("connected")
+	expectRead("welcome")
+
+	if _, err := clientConn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	expectRead("ping")
+
+	if r.Upstream() != 4 || r.Downstream() != 11 {
+		t.Fatalf("unexpected counters %d/%d", r.Upstream(), r.Downstream())
+	}
+
+	// closing the inbound channel closes the backend connection
+	inbound.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for r.Outbound().IsActive() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if r.Outbound().IsActive() {
+		t.Fatal("expected the outbound channel to be closed")
+	}
+}
+
+func TestDialError(t *testing.T) {
+	// a port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	_ = l.Close()
+
+	errC := make(chan error, 1)
+	Dial(channel.NewEmbeddedChannel(), "tcp", address, nil, func(r *Relay, err error) {
+		if r != nil {
+			t.Error("expected no relay")
+		}
+
+		errC <- err
+	})
+
+	select {
+	case err := <-errC:
+		if err == nil {
+			t.Fatal("expected the dial error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the dial error")
+	}
+}
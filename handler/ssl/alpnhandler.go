@@ -0,0 +1,66 @@
+package ssl
+
+import (
+	"ngio/channel"
+)
+
+// the application protocols of the IANA registry most servers negotiate
+const (
+	ProtocolHTTP2  = "h2"
+	ProtocolHTTP11 = "http/1.1"
+)
+
+// ApplicationProtocolNegotiationHandler sets up the pipeline of the application protocol negotiated by
+// ALPN, it follows the SslHandler and removes itself once the handshake completed. The protocols offered
+// are the NextProtos of the tls.Config.
+//
+//	ch.Pipeline().AddLast("alpn", ssl.NewApplicationProtocolNegotiationHandler(ssl.ProtocolHTTP11,
+//		func(pipeline *channel.Pipeline, name, protocol string) {
+//			switch protocol {
+//			case ssl.ProtocolHTTP2:
+//				pipeline.Replace(name, "h2", http2Handler)
+//			default:
+//				pipeline.Replace(name, "http", httpHandler)
+//			}
+//		}))
+type ApplicationProtocolNegotiationHandler struct {
+	fallbackProtocol string
+	configure        func(pipeline *channel.Pipeline, name, protocol string)
+	removed          bool
+}
+
+// NewApplicationProtocolNegotiationHandler creates the handler, configure gets fallbackProtocol if the
+// peer negotiated none. name is the one of the handler, which configure may replace.
+func NewApplicationProtocolNegotiationHandler(fallbackProtocol string, configure func(pipeline *channel.Pipeline, name, protocol string)) *ApplicationProtocolNegotiationHandler {
+	return &ApplicationProtocolNegotiationHandler{
+		fallbackProtocol: fallbackProtocol,
+		configure:        configure,
+	}
+}
+
+func (handler *ApplicationProtocolNegotiationHandler) HandlerRemoved(ctx *channel.Context) {
+	handler.removed = true
+}
+
+func (handler *ApplicationProtocolNegotiationHandler) UserEventTriggered(ctx *channel.Context, evt interface{}) {
+	completion, ok := evt.(HandshakeCompletionEvent)
+
+	// a failed handshake closes the channel, nothing to set up
+	if !ok || completion.Err != nil {
+		ctx.FireUserEventHandler(evt)
+		return
+	}
+
+	protocol := completion.State.NegotiatedProtocol
+	if protocol == "" {
+		protocol = handler.fallbackProtocol
+	}
+
+	handler.configure(ctx.Pipeline(), ctx.Name(), protocol)
+	if !handler.removed {
+		ctx.Pipeline().Remove(ctx.Name())
+	}
+
+	// the handlers of the protocol now follow the previous context, they learn about the handshake too
+	ctx.Prev().FireUserEventHandler(evt)
+}
@@ -0,0 +1,50 @@
+package ssl
+
+import (
+	"crypto/tls"
+	"ngio/channel"
+	"testing"
+)
+
+type eventRecorder struct {
+	events []interface{}
+}
+
+func (recorder *eventRecorder) UserEventTriggered(ctx *channel.Context, evt interface{}) {
+	recorder.events = append(recorder.events, evt)
+}
+
+func TestApplicationProtocolNegotiationHandler(t *testing.T) {
+	for negotiated, expected := range map[string]string{ProtocolHTTP2: ProtocolHTTP2, "": ProtocolHTTP11} {
+		var configured string
+		recorder := &eventRecorder{}
+
+		ch := channel.NewEmbeddedChannel(NewApplicationProtocolNegotiationHandler(ProtocolHTTP11,
+			func(pipeline *channel.Pipeline, name, protocol string) {
+				configured = protocol
+				pipeline.Replace(name, protocol, recorder)
+			}))
+
+		evt := HandshakeCompletionEvent{State: tls.ConnectionState{NegotiatedProtocol: negotiated}}
+		ch.Pipeline().FireUserEventHandler(evt)
+
+		if configured != expected {
+			t.Fatalf("expected %q to be configured, got %q", expected, configured)
+		}
+
+		if len(recorder.events) != 1 {
+			t.Fatalf("expected the handshake event to reach the configured handler, got %v", recorder.events)
+		}
+
+		// later events go to the configured handler only
+		ch.Pipeline().FireUserEventHandler(evt)
+
+		if len(recorder.events) != 2 {
+			t.Fatalf("expected the handler to be removed, got %v", recorder.events)
+		}
+
+		if err := ch.CheckError(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package ssl
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"io"
+	"ngio/logger"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrInvalidPollInterval = errors.New("ssl: the poll interval must be positive")
+
+// how many session ticket keys are kept, tickets issued with the older ones can still be resumed
+const maxTicketKeys = 3
+
+// CertReloadEvent is passed to the hook of a CertReloader after each reload, Err is set if it failed
+// and the previous certificate is still in use.
+type CertReloadEvent struct {
+	CertFile, KeyFile string
+	Err               error
+}
+
+// CertReloader serves a certificate from PEM files and reloads it when they change, so new handshakes
+// use the new certificate while established connections go on. It may also rotate the session ticket
+// keys of the configs it created.
+//
+//	reloader, err := ssl.NewCertReloader("cert.pem", "key.pem", time.Minute)
+//	reloader.RotateTicketKeys(24 * time.Hour)
+//	reloader.Start()
+//	server.Option(option.TLS(reloader.Config(&tls.Config{})))
+type CertReloader struct {
+	certFile, keyFile string
+	pollInterval      time.Duration
+	ticketRotation    time.Duration
+	cert              atomic.Value // *tls.Certificate
+	certStat, keyStat os.FileInfo
+	mu                sync.Mutex
+	configs           []*tls.Config
+	ticketKeys        [][32]byte
+	hook              func(CertReloadEvent)
+	startOnce         sync.Once
+	closeC            chan struct{}
+	closeOnce         sync.Once
+	log               logger.Logger
+}
+
+// NewCertReloader loads the certificate, then checks the files for changes every pollInterval once started.
+func NewCertReloader(certFile, keyFile string, pollInterval time.Duration) (*CertReloader, error) {
+	if pollInterval <= 0 {
+		return nil, ErrInvalidPollInterval
+	}
+
+	reloader := &CertReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		pollInterval: pollInterval,
+		closeC:       make(chan struct{}),
+		log:          logger.DefaultLogger(),
+	}
+
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// OnReload sets the hook called after each reload, whether it succeeded or not.
+func (reloader *CertReloader) OnReload(hook func(CertReloadEvent)) {
+	reloader.mu.Lock()
+	reloader.hook = hook
+	reloader.mu.Unlock()
+}
+
+// RotateTicketKeys makes the started reloader replace the session ticket key of its configs every d.
+// It takes effect on Start, so call it before.
+func (reloader *CertReloader) RotateTicketKeys(d time.Duration) {
+	reloader.mu.Lock()
+	reloader.ticketRotation = d
+	reloader.mu.Unlock()
+}
+
+// GetCertificate returns the current certificate, it is the tls.Config.GetCertificate of the configs.
+func (reloader *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return reloader.cert.Load().(*tls.Certificate), nil
+}
+
+// Config returns a copy of base serving the reloaded certificate, with rotated session ticket keys.
+func (reloader *CertReloader) Config(base *tls.Config) *tls.Config {
+	config := base.Clone()
+	config.Certificates = nil
+	config.GetCertificate = reloader.GetCertificate
+
+	reloader.mu.Lock()
+	reloader.configs = append(reloader.configs, config)
+	if len(reloader.ticketKeys) > 0 {
+		config.SetSessionTicketKeys(reloader.ticketKeys)
+	}
+	reloader.mu.Unlock()
+
+	return config
+}
+
+// Start starts polling the files, and rotating the ticket keys if set. Later calls do nothing.
+func (reloader *CertReloader) Start() {
+	reloader.startOnce.Do(func() {
+		reloader.mu.Lock()
+		ticketRotation := reloader.ticketRotation
+		reloader.mu.Unlock()
+
+		if ticketRotation > 0 {
+			reloader.rotateTicketKeys()
+		}
+
+		go reloader.run(ticketRotation)
+	})
+}
+
+func (reloader *CertReloader) Close() {
+	reloader.closeOnce.Do(func() {
+		close(reloader.closeC)
+	})
+}
+
+func (reloader *CertReloader) run(ticketRotation time.Duration) {
+	poll := time.NewTicker(reloader.pollInterval)
+	defer poll.Stop()
+
+	// a nil channel never fires when tickets are not rotated
+	var rotateC <-chan time.Time
+	if ticketRotation > 0 {
+		rotate := time.NewTicker(ticketRotation)
+		defer rotate.Stop()
+		rotateC = rotate.C
+	}
+
+	for {
+		select {
+		case <-reloader.closeC:
+			return
+		case <-poll.C:
+			if reloader.changed() {
+				reloader.reload()
+			}
+		case <-rotateC:
+			reloader.rotateTicketKeys()
+		}
+	}
+}
+
+// changed reports whether a file changed since the last load, missing files are reported once.
+func (reloader *CertReloader) changed() bool {
+	certStat, certErr := os.Stat(reloader.certFile)
+	keyStat, keyErr := os.Stat(reloader.keyFile)
+
+	if certErr != nil || keyErr != nil {
+		return reloader.certStat != nil && reloader.keyStat != nil
+	}
+
+	return !sameFile(certStat, reloader.certStat) || !sameFile(keyStat, reloader.keyStat)
+}
+
+func sameFile(a, b os.FileInfo) bool {
+	return b != nil && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+func (reloader *CertReloader) reload() {
+	err := reloader.load()
+	if err != nil {
+		reloader.log.Errorf("[cert: %v, key: %v] reload certificate\r\n %v", reloader.certFile, reloader.keyFile, err)
+	} else {
+		reloader.log.Infof("[cert: %v, key: %v] certificate reloaded", reloader.certFile, reloader.keyFile)
+	}
+
+	reloader.mu.Lock()
+	hook := reloader.hook
+	reloader.mu.Unlock()
+
+	if hook != nil {
+		hook(CertReloadEvent{CertFile: reloader.certFile, KeyFile: reloader.keyFile, Err: err})
+	}
+}
+
+// load replaces the certificate if both files could be read and parsed, the old one is kept otherwise.
+// The files are only loaded again once they change.
+func (reloader *CertReloader) load() error {
+	certStat, certErr := os.Stat(reloader.certFile)
+	keyStat, keyErr := os.Stat(reloader.keyFile)
+	reloader.certStat, reloader.keyStat = certStat, keyStat
+
+	if certErr != nil {
+		return certErr
+	}
+
+	if keyErr != nil {
+		return keyErr
+	}
+
+	cert, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return err
+	}
+
+	reloader.cert.Store(&cert)
+	return nil
+}
+
+func (reloader *CertReloader) rotateTicketKeys() {
+	var key [32]byte
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		reloader.log.Errorf("rotate session ticket keys\r\n %v", err)
+		return
+	}
+
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	// the new key encrypts new tickets, the previous ones still decrypt older tickets
+	reloader.ticketKeys = append([][32]byte{key}, reloader.ticketKeys...)
+	if len(reloader.ticketKeys) > maxTicketKeys {
+		reloader.ticketKeys = reloader.ticketKeys[:maxTicketKeys]
+	}
+
+	for _, config := range reloader.configs {
+		config.SetSessionTicketKeys(reloader.ticketKeys)
+	}
+
+	reloader.log.Debugf("session ticket keys rotated")
+}
@@ -0,0 +1,168 @@
+package ssl
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a new certificate of commonName to the files, dated in the future so
+// the reloader sees a change even within the resolution of the file times.
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string, age time.Duration) []byte {
+	certPEM, keyPEM := testCertificate(t, commonName)
+
+	for file, b := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+		if err := ioutil.WriteFile(file, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		modTime := time.Now().Add(age)
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert.Certificate[0]
+}
+
+func currentCertificate(t *testing.T, reloader *CertReloader) []byte {
+	cert, err := reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert.Certificate[0]
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certreloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	first := writeCertificate(t, certFile, keyFile, "first", 0)
+
+	if _, err := NewCertReloader(certFile, keyFile, 0); err != ErrInvalidPollInterval {
+		t.Fatalf("expected %v, got %v", ErrInvalidPollInterval, err)
+	}
+
+	reloader, err := NewCertReloader(certFile, keyFile, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(currentCertificate(t, reloader), first) {
+		t.Fatal("expected the first certificate")
+	}
+
+	eventC := make(chan CertReloadEvent, 1)
+	reloader.OnReload(func(evt CertReloadEvent) {
+		eventC <- evt
+	})
+
+	reloader.Start()
+	defer reloader.Close()
+
+	event := func() CertReloadEvent {
+		select {
+		case evt := <-eventC:
+			return evt
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for a reload")
+			return CertReloadEvent{}
+		}
+	}
+
+	second := writeCertificate(t, certFile, keyFile, "second", time.Minute)
+
+	// the poll may see the new certificate before the new key, then a later one loads both
+	evt := event()
+	for evt.Err != nil {
+		evt = event()
+	}
+
+	if evt.CertFile != certFile || evt.KeyFile != keyFile {
+		t.Fatalf("unexpected reload %+v", evt)
+	}
+
+	if !bytes.Equal(currentCertificate(t, reloader), second) {
+		t.Fatal("expected the second certificate")
+	}
+
+	// a certificate which does not parse keeps the previous one
+	if err := ioutil.WriteFile(certFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if evt := event(); evt.Err == nil {
+		t.Fatal("expected the reload to fail")
+	}
+
+	if !bytes.Equal(currentCertificate(t, reloader), second) {
+		t.Fatal("expected the second certificate to be kept")
+	}
+}
+
+func TestCertReloaderTicketKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certreloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, certFile, keyFile, "tickets", 0)
+
+	reloader, err := NewCertReloader(certFile, keyFile, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloader.Config(&tls.Config{})
+
+	// started twice, the keys are rotated once
+	reloader.RotateTicketKeys(time.Hour)
+	reloader.Start()
+	reloader.Start()
+	defer reloader.Close()
+
+	if len(reloader.ticketKeys) != 1 {
+		t.Fatalf("expected 1 key once started, got %d", len(reloader.ticketKeys))
+	}
+
+	reloader.mu.Lock()
+	reloader.ticketKeys = nil
+	reloader.mu.Unlock()
+
+	var previous [32]byte
+	for i := 1; i <= maxTicketKeys+2; i++ {
+		reloader.rotateTicketKeys()
+
+		expected := i
+		if expected > maxTicketKeys {
+			expected = maxTicketKeys
+		}
+
+		if len(reloader.ticketKeys) != expected {
+			t.Fatalf("expected %d keys, got %d", expected, len(reloader.ticketKeys))
+		}
+
+		// the new key comes first, the previous one is kept after it
+		if i > 1 && reloader.ticketKeys[1] != previous {
+			t.Fatal("expected the previous key second")
+		}
+
+		previous = reloader.ticketKeys[0]
+	}
+}
@@ -0,0 +1,135 @@
+package ssl
+
+import (
+	"crypto/tls"
+	"errors"
+	"ngio/channel"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNoConfigForServerName = errors.New("ssl: no tls config for server name")
+)
+
+// SniEntry is what a server name is mapped to, the config of its handshake and the initializer
+// setting up the pipeline of its connections. A nil Config falls back to the default one, a nil
+// Initializer leaves the pipeline as it is.
+type SniEntry struct {
+	Config      *tls.Config
+	Initializer channel.Initializer
+}
+
+// SniMapping maps server names to entries. Names are exact, like "example.com", or wildcards,
+// like "*.example.com" which matches "a.example.com" but neither "example.com" nor "a.b.example.com".
+// Names matching no entry, and clients sending none, get the default entry.
+type SniMapping struct {
+	mu           sync.RWMutex
+	entries      map[string]SniEntry
+	defaultEntry SniEntry
+}
+
+func NewSniMapping(defaultConfig *tls.Config, defaultInitializer channel.Initializer) *SniMapping {
+	return &SniMapping{
+		entries:      make(map[string]SniEntry),
+		defaultEntry: SniEntry{Config: defaultConfig, Initializer: defaultInitializer},
+	}
+}
+
+func (mapping *SniMapping) Add(hostname string, config *tls.Config, initializer channel.Initializer) *SniMapping {
+	mapping.mu.Lock()
+	mapping.entries[normalizeHostname(hostname)] = SniEntry{Config: config, Initializer: initializer}
+	mapping.mu.Unlock()
+
+	return mapping
+}
+
+func (mapping *SniMapping) Lookup(hostname string) SniEntry {
+	hostname = normalizeHostname(hostname)
+
+	mapping.mu.RLock()
+	defer mapping.mu.RUnlock()
+
+	if hostname != "" {
+		if entry, ok := mapping.entries[hostname]; ok {
+			return entry
+		}
+
+		if i := strings.IndexByte(hostname, '.'); i > 0 {
+			if entry, ok := mapping.entries["*"+hostname[i:]]; ok {
+				return entry
+			}
+		}
+	}
+
+	return mapping.defaultEntry
+}
+
+func normalizeHostname(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(hostname), ".")
+}
+
+// SniCompletionEvent is fired as user event once the server name of a connection is known,
+// after the initializer of its entry set up the pipeline. Hostname is empty if the client sent none.
+type SniCompletionEvent struct {
+	Hostname string
+}
+
+// SniHandler is the SslHandler of a server hosting several names. It picks the config and the
+// initializer of the name the client asks for in its ClientHello, so each name may have its own
+// certificates and handlers.
+//
+//	mapping := ssl.NewSniMapping(defaultConfig, defaultInitializer).
+//		Add("example.com", exampleConfig, exampleInitializer).
+//		Add("*.example.org", orgConfig, orgInitializer)
+//
+//	server.Channel(func(ch channel.Channel) {
+//		ch.Pipeline().AddFirst("sni", ssl.NewSniHandler(mapping, 10*time.Second))
+//	})
+type SniHandler struct {
+	*SslHandler
+	mapping  *SniMapping
+	hostname atomic.Value // string, set on the handshake goroutine
+}
+
+func NewSniHandler(mapping *SniMapping, handshakeTimeout time.Duration) *SniHandler {
+	handler := &SniHandler{
+		mapping: mapping,
+	}
+
+	handler.SslHandler = NewSslHandler(&tls.Config{GetConfigForClient: handler.selectConfig}, false, handshakeTimeout)
+	return handler
+}
+
+// Hostname returns the server name the client asked for, empty until the ClientHello arrived.
+func (handler *SniHandler) Hostname() string {
+	hostname, _ := handler.hostname.Load().(string)
+	return hostname
+}
+
+// selectConfig runs on the handshake, before any plaintext is read, so the entry's initializer
+// sets up the pipeline in time. The initializer runs on the read path, like the other handlers.
+func (handler *SniHandler) selectConfig(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	handler.hostname.Store(hello.ServerName)
+
+	entry := handler.mapping.Lookup(hello.ServerName)
+	if entry.Config == nil {
+		entry.Config = handler.mapping.defaultEntry.Config
+	}
+
+	if entry.Config == nil {
+		return nil, ErrNoConfigForServerName
+	}
+
+	handler.run(func() {
+		if entry.Initializer != nil {
+			entry.Initializer(handler.ctx.Pipeline().Channel())
+		}
+
+		handler.ctx.FireUserEventHandler(SniCompletionEvent{Hostname: hello.ServerName})
+	})
+
+	return entry.Config, nil
+}
@@ -0,0 +1,94 @@
+package ssl
+
+import (
+	"crypto/tls"
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+	"time"
+)
+
+func TestSniMappingLookup(t *testing.T) {
+	defaultConfig := &tls.Config{ServerName: "default"}
+	exactConfig := &tls.Config{ServerName: "example.com"}
+	wildcardConfig := &tls.Config{ServerName: "*.example.org"}
+
+	mapping := NewSniMapping(defaultConfig, nil).
+		Add("example.com", exactConfig, nil).
+		Add("*.Example.org.", wildcardConfig, nil)
+
+	for _, tc := range []struct {
+		hostname string
+		expected *tls.Config
+	}{
+		{"example.com", exactConfig},
+		{"EXAMPLE.com", exactConfig},
+		{"example.com.", exactConfig},
+		{"a.example.com", defaultConfig},
+		{"a.example.org", wildcardConfig},
+		{"A.Example.Org.", wildcardConfig},
+		{"example.org", defaultConfig},
+		{"a.b.example.org", defaultConfig},
+		{"", defaultConfig},
+		{"unknown.net", defaultConfig},
+	} {
+		if actual := mapping.Lookup(tc.hostname).Config; actual != tc.expected {
+			t.Fatalf("%q: expected the config of %q, got %q", tc.hostname, tc.expected.ServerName, actual.ServerName)
+		}
+	}
+}
+
+func TestSniHandler(t *testing.T) {
+	exampleConfig := testServerConfig(t)
+
+	var initialized string
+
+	// example.org shares the default config but has its own pipeline
+	mapping := NewSniMapping(exampleConfig, nil).
+		Add("example.org", nil, func(ch channel.Channel) {
+			initialized = "example.org"
+		})
+
+	handler := NewSniHandler(mapping, time.Second)
+	recorder := newHandshakeRecorder()
+	_, clientConn, _ := serveSsl(handler, recorder)
+
+	client := tls.Client(clientConn, &tls.Config{ServerName: "example.org", InsecureSkipVerify: true})
+	defer client.Close()
+
+	if err := client.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	if evt := recorder.event(t); evt.Err != nil {
+		t.Fatal(evt.Err)
+	}
+
+	if handler.Hostname() != "example.org" || initialized != "example.org" {
+		t.Fatalf("expected example.org, got hostname %q and initializer of %q", handler.Hostname(), initialized)
+	}
+}
+
+func TestSniHandlerInitializerOnReadPath(t *testing.T) {
+	recorder := &eventRecorder{}
+
+	mapping := NewSniMapping(testServerConfig(t), nil).
+		Add("example.org", nil, func(ch channel.Channel) {
+			ch.Pipeline().AddLast("recorder", recorder)
+		})
+
+	ch := channel.NewEmbeddedChannel(NewSniHandler(mapping, time.Second))
+	defer ch.Close()
+
+	// the initializer and the event are done with once the ClientHello is read
+	hello := clientHello(t, "example.org")
+	ch.WriteInbound(buffer.NewByteBuf(hello, 0, len(hello)))
+
+	if len(recorder.events) != 1 || recorder.events[0].(SniCompletionEvent).Hostname != "example.org" {
+		t.Fatalf("expected the event of example.org, got %v", recorder.events)
+	}
+
+	if ch.ReadOutbound() == nil {
+		t.Fatal("expected the ServerHello")
+	}
+}
@@ -0,0 +1,354 @@
+package ssl
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"ngio/buffer"
+	"ngio/channel"
+	"ngio/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrHandshakeTimeout = errors.New("ssl: handshake timed out")
+)
+
+// the largest plaintext of a TLS record
+const maxPlaintext = 16384
+
+// HandshakeCompletionEvent is fired as user event once the handshake completed. Err is set if it
+// failed, the channel is closed then.
+type HandshakeCompletionEvent struct {
+	State tls.ConnectionState
+	Err   error
+}
+
+// SslHandler encrypts the ByteBuffer written through it and decrypts the ByteBuffer read through it.
+// It is usually the first handler of a pipeline, the ones after it only see plaintext. The handshake
+// starts once the channel is active, handlers learn its outcome by a HandshakeCompletionEvent. The
+// handshake runs on its own goroutine, yet the event and the plaintext reach the handlers on the read path.
+//
+//	ch.Pipeline().AddFirst("ssl", ssl.NewSslHandler(config, false, 10*time.Second))
+type SslHandler struct {
+	config           *tls.Config
+	isClient         bool
+	handshakeTimeout time.Duration
+	conn             *recordConn
+	tlsConn          *tls.Conn
+	ctx              *channel.Context
+	startOnce        sync.Once
+	handshakeDone    int32
+	cancelTimeout    func()
+	taskC            chan func()
+	doneC            chan struct{}
+	closedC          chan struct{}
+	closeOnce        sync.Once
+	log              logger.Logger
+}
+
+// NewSslHandler creates the handler of the client or the server side of a connection.
+// A zero handshakeTimeout lets the handshake take as long as it takes, otherwise it is timed by the
+// scheduler of the channel, see channel.SchedulerOf.
+func NewSslHandler(config *tls.Config, isClient bool, handshakeTimeout time.Duration) *SslHandler {
+	handler := &SslHandler{
+		config:           config,
+		isClient:         isClient,
+		handshakeTimeout: handshakeTimeout,
+		conn:             newRecordConn(),
+		taskC:            make(chan func()),
+		doneC:            make(chan struct{}),
+		closedC:          make(chan struct{}),
+		log:              logger.DefaultLogger(),
+	}
+
+	// the TLS records go through the conn, which reads and writes at the position of this handler
+	if isClient {
+		handler.tlsConn = tls.Client(handler.conn, config)
+	} else {
+		handler.tlsConn = tls.Server(handler.conn, config)
+	}
+
+	return handler
+}
+
+// NewStartTLSHandler creates the SslHandler of a channel which is already active, after the peers
+// agreed in plaintext to switch to TLS. Once added, every byte read or written is encrypted, including
+// the ones the decoder right after it already received but did not decode yet.
+//
+//	ctx.Write(okResponse)
+//	ctx.Pipeline().AddFirst("tls", ssl.NewStartTLSHandler(config, false))
+func NewStartTLSHandler(config *tls.Config, isClient bool) *SslHandler {
+	return NewSslHandler(config, isClient, 0)
+}
+
+func (handler *SslHandler) HandlerAdded(ctx *channel.Context) {
+	handler.ctx = ctx
+	handler.conn.HandlerAdded(ctx)
+
+	// added to a channel which is already active, no ChannelActive will follow
+	if ctx.Pipeline().Channel().IsActive() {
+		drained := handler.drainInbound(ctx)
+		handler.start()
+
+		if drained {
+			handler.process()
+		}
+	}
+}
+
+// drainInbound takes over the bytes the decoder right after this one received but did not decode yet,
+// with STARTTLS they may already be the start of the handshake. The handlers further down only hold
+// what that decoder already decoded, which is no TLS record. It reports whether it took over any byte.
+func (handler *SslHandler) drainInbound(ctx *channel.Context) bool {
+	next := ctx.Next()
+	if next == nil {
+		return false
+	}
+
+	if holder, ok := next.Handler().(channel.InboundBufferHolder); ok {
+		if bf := holder.DrainInboundBuffer(); bf != nil {
+			return handler.conn.feed(ctx, bf)
+		}
+	}
+
+	return false
+}
+
+func (handler *SslHandler) HandlerRemoved(ctx *channel.Context) {
+	handler.conn.HandlerRemoved(ctx)
+	handler.closeTasks()
+}
+
+func (handler *SslHandler) ChannelActive(ctx *channel.Context) {
+	handler.start()
+	ctx.FireActiveHandler()
+}
+
+func (handler *SslHandler) ChannelInActive(ctx *channel.Context) {
+	// closed before the handshake completed, which may still hold the state of the connection
+	handler.completeHandshake(tls.ConnectionState{}, io.EOF)
+
+	// the channel still takes the close_notify alert, it is flushed before the connection is closed
+	_ = handler.tlsConn.Close()
+	handler.conn.ChannelInActive(ctx)
+	handler.closeTasks()
+}
+
+// ChannelRead passes the TLS records to the handshake goroutine, then runs what it hands back to the
+// pipeline until it consumed them, see process.
+func (handler *SslHandler) ChannelRead(ctx *channel.Context, msg interface{}) {
+	if handler.conn.feed(ctx, msg) {
+		handler.process()
+	}
+}
+
+func (handler *SslHandler) Write(ctx *channel.Context, msg interface{}) {
+	bf, ok := msg.(buffer.ByteBuffer)
+	if !ok {
+		ctx.Write(msg)
+		return
+	}
+
+	// blocks until the handshake completed
+	if _, err := handler.tlsConn.Write(bf.ReadBytes(bf.ReadableBytes())); err != nil {
+		// a failed handshake already closed the channel
+		if ch := ctx.Pipeline().Channel(); ch.IsActive() {
+			handler.log.Errorf("[%v] tls write\r\n %v", ch, err)
+			ctx.FireChannelErrorHandler(err)
+			ch.Close()
+		}
+	}
+}
+
+// Close sends a close_notify alert to the peer, then closes the channel.
+// Closing the channel directly sends the alert as well.
+func (handler *SslHandler) Close() error {
+	return handler.tlsConn.Close()
+}
+
+// ConnectionState returns the state of the connection, complete once the handshake completed.
+func (handler *SslHandler) ConnectionState() tls.ConnectionState {
+	return handler.tlsConn.ConnectionState()
+}
+
+func (handler *SslHandler) start() {
+	handler.startOnce.Do(func() {
+		if handler.handshakeTimeout > 0 {
+			handler.cancelTimeout = channel.SchedulerOf(handler.ctx.Pipeline().Channel()).Schedule(handler.handshakeTimeout, func() {
+				// the handshake goroutine still holds the state of the connection
+				handler.completeHandshake(tls.ConnectionState{}, ErrHandshakeTimeout)
+			})
+		}
+
+		go handler.serve()
+	})
+}
+
+// run hands task over to the read path and waits until it ran there, so the handlers are not called from
+// the handshake goroutine. task is dropped once the channel is closed.
+func (handler *SslHandler) run(task func()) {
+	done := make(chan struct{})
+
+	select {
+	case handler.taskC <- func() {
+		defer close(done)
+		task()
+	}:
+		<-done
+	case <-handler.closedC:
+	}
+}
+
+// process runs the tasks of the handshake goroutine on the read path, until that goroutine consumed the
+// records fed or returned.
+func (handler *SslHandler) process() {
+	for {
+		select {
+		case task := <-handler.taskC:
+			task()
+		case <-handler.conn.idleC:
+			return
+		case <-handler.doneC:
+			return
+		}
+	}
+}
+
+func (handler *SslHandler) closeTasks() {
+	handler.closeOnce.Do(func() {
+		close(handler.closedC)
+	})
+}
+
+func (handler *SslHandler) serve() {
+	defer close(handler.doneC)
+
+	ch := handler.ctx.Pipeline().Channel()
+
+	if err := handler.tlsConn.Handshake(); err != nil {
+		handler.run(func() {
+			handler.completeHandshake(handler.tlsConn.ConnectionState(), err)
+		})
+		return
+	}
+
+	handler.run(func() {
+		if handler.cancelTimeout != nil {
+			handler.cancelTimeout()
+		}
+
+		handler.completeHandshake(handler.tlsConn.ConnectionState(), nil)
+	})
+
+	buf := make([]byte, maxPlaintext)
+
+	for {
+		n, err := handler.tlsConn.Read(buf)
+		if n > 0 {
+			b := make([]byte, n)
+			copy(b, buf[:n])
+			handler.run(func() {
+				handler.ctx.FireReadHandler(buffer.NewByteBuf(b, 0, n))
+			})
+		}
+
+		if err == nil {
+			continue
+		}
+
+		// the peer sent close_notify, or the channel was closed
+		if err == io.EOF {
+			_ = handler.tlsConn.Close()
+			return
+		}
+
+		handler.run(func() {
+			if ch.IsActive() {
+				handler.ctx.FireChannelErrorHandler(err)
+				ch.Close()
+			}
+		})
+
+		return
+	}
+}
+
+// completeHandshake fires the HandshakeCompletionEvent, once, whichever of the handshake, its timeout
+// and the channel closing ends it first. A failed handshake closes the channel.
+func (handler *SslHandler) completeHandshake(state tls.ConnectionState, err error) {
+	if !atomic.CompareAndSwapInt32(&handler.handshakeDone, 0, 1) {
+		return
+	}
+
+	ch := handler.ctx.Pipeline().Channel()
+
+	if err != nil {
+		handler.log.Errorf("[%v] tls handshake\r\n %v", ch, err)
+		handler.ctx.FireUserEventHandler(HandshakeCompletionEvent{State: state, Err: err})
+		ch.Close()
+		return
+	}
+
+	handler.log.Debugf("[%v] tls handshake completed", ch)
+	handler.ctx.FireUserEventHandler(HandshakeCompletionEvent{State: state})
+}
+
+// recordConn is the conn the TLS records are read from. It tells the read path once the handshake
+// goroutine consumed every record fed so far and waits for more.
+type recordConn struct {
+	*channel.Conn
+	mu       sync.Mutex
+	buffered int
+	idleC    chan struct{}
+}
+
+func newRecordConn() *recordConn {
+	return &recordConn{
+		Conn:  channel.NewConn(),
+		idleC: make(chan struct{}, 1),
+	}
+}
+
+// feed passes msg to the conn, it reports whether there were bytes to consume.
+func (c *recordConn) feed(ctx *channel.Context, msg interface{}) bool {
+	bf, ok := msg.(buffer.ByteBuffer)
+	if !ok || bf.ReadableBytes() == 0 {
+		c.Conn.ChannelRead(ctx, msg)
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the idle signal of the bytes fed before is stale
+	select {
+	case <-c.idleC:
+	default:
+	}
+
+	c.buffered += bf.ReadableBytes()
+	c.Conn.ChannelRead(ctx, msg)
+	return true
+}
+
+func (c *recordConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	if c.buffered == 0 {
+		select {
+		case c.idleC <- struct{}{}:
+		default:
+		}
+	}
+	c.mu.Unlock()
+
+	n, err := c.Conn.Read(b)
+
+	c.mu.Lock()
+	c.buffered -= n
+	c.mu.Unlock()
+
+	return n, err
+}
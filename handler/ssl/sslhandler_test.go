@@ -0,0 +1,400 @@
+package ssl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"ngio/buffer"
+	"ngio/channel"
+	"ngio/codec"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCertificate returns a self-signed certificate of commonName and its key, PEM encoded.
+func testCertificate(t *testing.T, commonName string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func testServerConfig(t *testing.T) *tls.Config {
+	cert, err := tls.X509KeyPair(testCertificate(t, "localhost"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &tls.Config{Certificates: []tls.Certificate{cert}}
+}
+
+type handshakeRecorder struct {
+	eventC chan HandshakeCompletionEvent
+	readC  chan string
+}
+
+func newHandshakeRecorder() *handshakeRecorder {
+	return &handshakeRecorder{
+		eventC: make(chan HandshakeCompletionEvent, 1),
+		readC:  make(chan string, 1),
+	}
+}
+
+func (recorder *handshakeRecorder) UserEventTriggered(ctx *channel.Context, evt interface{}) {
+	if e, ok := evt.(HandshakeCompletionEvent); ok {
+		recorder.eventC <- e
+	}
+}
+
+func (recorder *handshakeRecorder) ChannelRead(ctx *channel.Context, msg interface{}) {
+	bf := msg.(buffer.ByteBuffer)
+	recorder.readC <- string(bf.ReadBytes(bf.ReadableBytes()))
+}
+
+func (recorder *handshakeRecorder) event(t *testing.T) HandshakeCompletionEvent {
+	select {
+	case evt := <-recorder.eventC:
+		return evt
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the handshake")
+		return HandshakeCompletionEvent{}
+	}
+}
+
+// recordingConn keeps the raw bytes read, to look for TLS records the tls package handles silently.
+type recordingConn struct {
+	net.Conn
+	mu   sync.Mutex
+	read []byte
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+
+	c.mu.Lock()
+	c.read = append(c.read, b[:n]...)
+	c.mu.Unlock()
+
+	return n, err
+}
+
+// recordTypes returns the content types of the TLS records read.
+func (c *recordingConn) recordTypes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var types []byte
+	for b := c.read; len(b) >= 5; {
+		n := 5 + int(b[3])<<8 + int(b[4])
+		if n > len(b) {
+			break
+		}
+
+		types = append(types, b[0])
+		b = b[n:]
+	}
+
+	return types
+}
+
+func serveSsl(handler interface{}, recorder *handshakeRecorder) (*channel.TCPChannel, net.Conn, chan error) {
+	clientConn, serverConn := net.Pipe()
+
+	ch := channel.NewTCPChannel(serverConn, 0, 0)
+	ch.Pipeline().AddLast("ssl", handler)
+	ch.Pipeline().AddLast("recorder", recorder)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- ch.Serve()
+	}()
+
+	return ch, clientConn, served
+}
+
+func TestSslHandler(t *testing.T) {
+	recorder := newHandshakeRecorder()
+	ch, clientConn, served := serveSsl(NewSslHandler(testServerConfig(t), false, time.Second), recorder)
+
+	// TLS 1.2 leaves the type of the alert records visible
+	raw := &recordingConn{Conn: clientConn}
+	client := tls.Client(raw, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+	defer client.Close()
+
+	if err := client.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	if evt := recorder.event(t); evt.Err != nil || !evt.State.HandshakeComplete {
+		t.Fatalf("expected a completed handshake, got %+v", evt)
+	}
+
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-recorder.readC:
+		if msg != "ping" {
+			t.Fatalf("expected ping, got %v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ping")
+	}
+
+	ch.Pipeline().FireWriteHandler(buffer.NewByteBuf([]byte("pong"), 0, 4))
+
+	b := make([]byte, 16)
+	n, err := client.Read(b)
+	if err != nil || string(b[:n]) != "pong" {
+		t.Fatalf("expected pong, got %q, %v", b[:n], err)
+	}
+
+	// closing the channel directly still sends close_notify
+	ch.Close()
+
+	if _, err := client.Read(b); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	types := raw.recordTypes()
+	if len(types) == 0 || types[len(types)-1] != 21 {
+		t.Fatalf("expected an alert record last, got record types %v", types)
+	}
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for close")
+	}
+}
+
+func TestSslHandlerHandshakeTimeout(t *testing.T) {
+	recorder := newHandshakeRecorder()
+	_, clientConn, served := serveSsl(NewSslHandler(testServerConfig(t), false, 50*time.Millisecond), recorder)
+	defer clientConn.Close()
+
+	// the client never sends its hello
+	if evt := recorder.event(t); evt.Err != ErrHandshakeTimeout {
+		t.Fatalf("expected %v, got %+v", ErrHandshakeTimeout, evt)
+	}
+
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for close")
+	}
+}
+
+func TestSslHandlerReadPath(t *testing.T) {
+	recorder := &eventRecorder{}
+	ch := channel.NewEmbeddedChannel(NewSslHandler(testServerConfig(t), false, time.Second), recorder)
+	defer ch.Close()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	// the client reads the records of the server on its own
+	outC := make(chan []byte, 16)
+	defer close(outC)
+
+	go func() {
+		for b := range outC {
+			_, _ = serverConn.Write(b)
+		}
+	}()
+
+	// deliver reads the next records of the client into the channel, and queues the ones written back
+	deliver := func() {
+		_ = serverConn.SetReadDeadline(time.Now().Add(time.Second))
+
+		b := make([]byte, 4096)
+		n, err := serverConn.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ch.WriteInbound(buffer.NewByteBuf(b, 0, n))
+
+		for msg := ch.ReadOutbound(); msg != nil; msg = ch.ReadOutbound() {
+			bf := msg.(buffer.ByteBuffer)
+			outC <- bf.ReadBytes(bf.ReadableBytes())
+		}
+	}
+
+	client := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true})
+	go func() {
+		_ = client.Handshake()
+	}()
+
+	// the event is fired by the read taking the last record of the handshake
+	for len(recorder.events) == 0 {
+		deliver()
+	}
+
+	if evt, ok := recorder.events[0].(HandshakeCompletionEvent); !ok || evt.Err != nil || !evt.State.HandshakeComplete {
+		t.Fatalf("expected a completed handshake, got %+v", recorder.events[0])
+	}
+
+	go func() {
+		_, _ = client.Write([]byte("ping"))
+	}()
+
+	deliver()
+
+	if bf, ok := ch.ReadInbound().(buffer.ByteBuffer); !ok || string(bf.ReadBytes(bf.ReadableBytes())) != "ping" {
+		t.Fatal("expected ping once the record is read")
+	}
+}
+
+func TestSslHandlerHandshakeTimeoutScheduler(t *testing.T) {
+	recorder := &eventRecorder{}
+	ch := channel.NewEmbeddedChannel(NewSslHandler(testServerConfig(t), false, time.Second), recorder)
+
+	ch.AdvanceTimeBy(time.Second)
+
+	if len(recorder.events) != 1 || recorder.events[0].(HandshakeCompletionEvent).Err != ErrHandshakeTimeout {
+		t.Fatalf("expected %v, got %v", ErrHandshakeTimeout, recorder.events)
+	}
+
+	if ch.IsActive() {
+		t.Fatal("expected the channel to be closed")
+	}
+}
+
+// startTLSHandler switches to TLS once it reads the STARTTLS command.
+type startTLSHandler struct {
+	config   *tls.Config
+	commands []string
+}
+
+func (handler *startTLSHandler) ChannelRead(ctx *channel.Context, msg interface{}) {
+	bf := msg.(buffer.ByteBuffer)
+	command := string(bf.ReadBytes(bf.ReadableBytes()))
+	handler.commands = append(handler.commands, command)
+
+	if command == "STARTTLS" {
+		ctx.Pipeline().AddFirst("tls", NewStartTLSHandler(handler.config, false))
+	}
+}
+
+// decodedHolder stands for a second decoder, which only holds bytes the first one already decoded.
+type decodedHolder struct {
+	drained bool
+}
+
+func (holder *decodedHolder) ChannelRead(ctx *channel.Context, msg interface{}) {
+	ctx.FireReadHandler(msg)
+}
+
+func (holder *decodedHolder) DrainInboundBuffer() buffer.ByteBuffer {
+	holder.drained = true
+	return buffer.NewByteBuf([]byte("decoded"), 0, 7)
+}
+
+type inactiveSignal struct {
+	inactiveC chan struct{}
+}
+
+func (signal *inactiveSignal) ChannelInActive(ctx *channel.Context) {
+	close(signal.inactiveC)
+}
+
+// clientHello returns the first record of a client handshake for serverName.
+func clientHello(t *testing.T, serverName string) []byte {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	client := tls.Client(clientConn, &tls.Config{ServerName: serverName})
+	defer client.Close()
+
+	go func() {
+		_ = client.Handshake()
+	}()
+
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(serverConn, header); err != nil {
+		t.Fatal(err)
+	}
+
+	record := make([]byte, 5+int(header[3])<<8+int(header[4]))
+	copy(record, header)
+
+	if _, err := io.ReadFull(serverConn, record[5:]); err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+func TestStartTLSHandler(t *testing.T) {
+	helloC := make(chan string, 1)
+	config := &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		helloC <- hello.ServerName
+
+		// ends the handshake, the ClientHello arriving intact is what matters
+		return nil, io.EOF
+	}}
+
+	starttls := &startTLSHandler{config: config}
+	holder := &decodedHolder{}
+	signal := &inactiveSignal{inactiveC: make(chan struct{})}
+
+	ch := channel.NewEmbeddedChannel(codec.NewByteToMessageDecoderAdapter(codec.NewLineBasedFrameDecoder(64, true)), holder, starttls, signal)
+
+	// the client sends its hello right after the command, both arrive in the same read
+	in := append([]byte("STARTTLS\r\n"), clientHello(t, "starttls.example")...)
+	ch.WriteInbound(buffer.NewByteBuf(in, 0, len(in)))
+
+	select {
+	case serverName := <-helloC:
+		if serverName != "starttls.example" {
+			t.Fatalf("expected starttls.example, got %q", serverName)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the ClientHello")
+	}
+
+	if len(starttls.commands) != 1 {
+		t.Fatalf("expected the STARTTLS command only, got %q", starttls.commands)
+	}
+
+	if holder.drained {
+		t.Fatal("expected the decoded bytes further down not to be drained")
+	}
+
+	select {
+	case <-signal.inactiveC:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for close")
+	}
+}
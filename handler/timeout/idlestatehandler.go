@@ -0,0 +1,181 @@
+package timeout
+
+import (
+	"ngio/channel"
+	"sync"
+	"time"
+)
+
+type IdleState int
+
+const (
+	// ReaderIdle is the state of a channel which read nothing for a while
+	ReaderIdle IdleState = iota
+	// WriterIdle is the state of a channel which wrote nothing for a while
+	WriterIdle
+	// AllIdle is the state of a channel which neither read nor wrote for a while
+	AllIdle
+)
+
+func (state IdleState) String() string {
+	switch state {
+	case ReaderIdle:
+		return "reader idle"
+	case WriterIdle:
+		return "writer idle"
+	case AllIdle:
+		return "all idle"
+	}
+
+	return "unknown"
+}
+
+// IdleStateEvent is fired as user event by an IdleStateHandler. First is set for the first event
+// of a state since the channel was last busy, the next ones follow each idle duration.
+type IdleStateEvent struct {
+	State IdleState
+	First bool
+}
+
+// IdleStateHandler fires IdleStateEvents once its channel did not read, write, or either of them for
+// the configured durations, a duration of 0 disables the state. Handle them after it to send heartbeats,
+// or close the channel:
+//
+//	pipeline.AddLast("idle", timeout.NewIdleStateHandler(time.Minute, 0, 0))
+//	pipeline.AddLast("handler", handler) // closes the channel on a ReaderIdle event
+//
+// A write counts once it is passed to the handlers before the IdleStateHandler, not once it is sent.
+type IdleStateHandler struct {
+	readerIdle, writerIdle, allIdle time.Duration
+
+	mu                  sync.Mutex
+	started, stopped    bool
+	lastRead, lastWrite time.Time
+	first               [3]bool
+	cancels             [3]func()
+}
+
+func NewIdleStateHandler(readerIdle, writerIdle, allIdle time.Duration) *IdleStateHandler {
+	return &IdleStateHandler{
+		readerIdle: readerIdle,
+		writerIdle: writerIdle,
+		allIdle:    allIdle,
+	}
+}
+
+func (handler *IdleStateHandler) HandlerAdded(ctx *channel.Context) {
+	if ctx.Pipeline().Channel().IsActive() {
+		handler.start(ctx)
+	}
+}
+
+func (handler *IdleStateHandler) HandlerRemoved(ctx *channel.Context) {
+	handler.stop()
+}
+
+func (handler *IdleStateHandler) ChannelActive(ctx *channel.Context) {
+	handler.start(ctx)
+	ctx.FireActiveHandler()
+}
+
+func (handler *IdleStateHandler) ChannelInActive(ctx *channel.Context) {
+	handler.stop()
+	ctx.FireInActiveHandler()
+}
+
+func (handler *IdleStateHandler) ChannelRead(ctx *channel.Context, msg interface{}) {
+	handler.mu.Lock()
+	handler.lastRead = channel.SchedulerOf(ctx.Pipeline().Channel()).Now()
+	handler.first[ReaderIdle], handler.first[AllIdle] = true, true
+	handler.mu.Unlock()
+
+	ctx.FireReadHandler(msg)
+}
+
+func (handler *IdleStateHandler) Write(ctx *channel.Context, msg interface{}) {
+	handler.mu.Lock()
+	handler.lastWrite = channel.SchedulerOf(ctx.Pipeline().Channel()).Now()
+	handler.first[WriterIdle], handler.first[AllIdle] = true, true
+	handler.mu.Unlock()
+
+	ctx.Write(msg)
+}
+
+func (handler *IdleStateHandler) start(ctx *channel.Context) {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	if handler.started {
+		return
+	}
+	handler.started = true
+
+	now := channel.SchedulerOf(ctx.Pipeline().Channel()).Now()
+	handler.lastRead, handler.lastWrite = now, now
+
+	for state, idle := range []time.Duration{handler.readerIdle, handler.writerIdle, handler.allIdle} {
+		if idle > 0 {
+			handler.first[state] = true
+			handler.schedule(ctx, IdleState(state), idle)
+		}
+	}
+}
+
+func (handler *IdleStateHandler) stop() {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	handler.stopped = true
+	for _, cancel := range handler.cancels {
+		if cancel != nil {
+			cancel()
+		}
+	}
+}
+
+// schedule checks the state once delay passed, it is called with mu held.
+func (handler *IdleStateHandler) schedule(ctx *channel.Context, state IdleState, delay time.Duration) {
+	handler.cancels[state] = channel.SchedulerOf(ctx.Pipeline().Channel()).Schedule(delay, func() {
+		handler.check(ctx, state)
+	})
+}
+
+func (handler *IdleStateHandler) check(ctx *channel.Context, state IdleState) {
+	handler.mu.Lock()
+
+	if handler.stopped {
+		handler.mu.Unlock()
+		return
+	}
+
+	var idle time.Duration
+	var last time.Time
+
+	switch state {
+	case ReaderIdle:
+		idle, last = handler.readerIdle, handler.lastRead
+	case WriterIdle:
+		idle, last = handler.writerIdle, handler.lastWrite
+	case AllIdle:
+		idle, last = handler.allIdle, handler.lastRead
+		if handler.lastWrite.After(last) {
+			last = handler.lastWrite
+		}
+	}
+
+	// busy in the meantime, check again once idle for the whole duration
+	next := idle - channel.SchedulerOf(ctx.Pipeline().Channel()).Now().Sub(last)
+	if next > 0 {
+		handler.schedule(ctx, state, next)
+		handler.mu.Unlock()
+		return
+	}
+
+	handler.schedule(ctx, state, idle)
+
+	first := handler.first[state]
+	handler.first[state] = false
+	handler.mu.Unlock()
+
+	ctx.FireUserEventHandler(IdleStateEvent{State: state, First: first})
+}
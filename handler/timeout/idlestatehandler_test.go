@@ -0,0 +1,47 @@
+package timeout
+
+import (
+	"ngio/buffer"
+	"ngio/channel"
+	"testing"
+	"time"
+)
+
+type eventRecorder struct {
+	events []IdleStateEvent
+}
+
+func (recorder *eventRecorder) UserEventTriggered(ctx *channel.Context, evt interface{}) {
+	recorder.events = append(recorder.events, evt.(IdleStateEvent))
+}
+
+func TestIdleStateHandler(t *testing.T) {
+	recorder := &eventRecorder{}
+	ch := channel.NewEmbeddedChannel(NewIdleStateHandler(time.Second, 2*time.Second, 0), recorder)
+
+	ch.AdvanceTimeBy(500 * time.Millisecond)
+	ch.WriteInbound(buffer.NewByteBuf([]byte("ping"), 0, 4))
+
+	// the read postponed the reader idle event
+	ch.AdvanceTimeBy(900 * time.Millisecond)
+	if len(recorder.events) != 0 {
+		t.Fatalf("unexpected events %v", recorder.events)
+	}
+
+	ch.AdvanceTimeBy(100 * time.Millisecond)
+	if len(recorder.events) != 1 || recorder.events[0] != (IdleStateEvent{State: ReaderIdle, First: true}) {
+		t.Fatalf("expected a first reader idle event, got %v", recorder.events)
+	}
+
+	ch.AdvanceTimeBy(time.Second)
+	if len(recorder.events) != 3 || recorder.events[1] != (IdleStateEvent{State: WriterIdle, First: true}) ||
+		recorder.events[2] != (IdleStateEvent{State: ReaderIdle, First: false}) {
+		t.Fatalf("expected a writer idle and another reader idle event, got %v", recorder.events)
+	}
+
+	ch.Close()
+	ch.AdvanceTimeBy(time.Minute)
+	if len(recorder.events) != 3 {
+		t.Fatalf("unexpected events after close %v", recorder.events)
+	}
+}
@@ -4,6 +4,8 @@ import "errors"
 
 var (
 	ErrBindAddrIsNil = errors.New("listener local addr is nil")
+	ErrListenerIsNil = errors.New("listener is nil")
+	ErrListenOption  = errors.New("listener: Backlog, ReusePort, Acceptors, TCPFastOpen, TCPDeferAccept and IPv6Only do not apply to an existing listener")
 )
 
 type Listener interface {
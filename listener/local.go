@@ -0,0 +1,83 @@
+package listener
+
+import (
+	"ngio/channel"
+	"ngio/logger"
+	"ngio/option"
+)
+
+type LocalListener struct {
+	addr        *channel.LocalAddr
+	acceptor    *channel.LocalAcceptor
+	opts        *option.Options
+	log         logger.Logger
+	initializer channel.Initializer
+}
+
+func NewLocalListener(laddr string, opts *option.Options, initializer channel.Initializer) (*LocalListener, error) {
+	if opts == nil {
+		return nil, option.ErrOptionIsNil
+	}
+
+	return &LocalListener{
+		addr:        channel.NewLocalAddr(laddr),
+		acceptor:    nil,
+		opts:        opts,
+		log:         logger.DefaultLogger(),
+		initializer: initializer,
+	}, nil
+}
+
+func (lsn *LocalListener) Serve() error {
+	if lsn.addr == nil {
+		return ErrBindAddrIsNil
+	}
+
+	acceptor, err := channel.ListenLocal(lsn.addr)
+	if err != nil {
+		return err
+	}
+
+	lsn.log.Infof("[network: %v, local: %v] listening", acceptor.Addr().Network(), acceptor.Addr())
+
+	lsn.acceptor = acceptor
+
+	for {
+		ch, err := lsn.acceptor.Accept()
+		if err != nil {
+			// forwardly close will return channel.ErrLocalAcceptorIsClosed
+			if err == channel.ErrLocalAcceptorIsClosed {
+				return nil
+			}
+
+			lsn.log.Errorf("local accept\r\n %v", err)
+			lsn.Shutdown()
+			return err
+		}
+
+		lsn.log.Debugf("[network: %v, remote: %v] accepted", ch.RemoteAddress().Network(), ch.RemoteAddress())
+
+		if lsn.initializer != nil {
+			lsn.initializer(ch)
+		}
+
+		go func() {
+			_ = ch.Serve()
+		}()
+	}
+}
+
+func (lsn *LocalListener) Shutdown() {
+	if lsn.acceptor == nil {
+		return
+	}
+
+	lsn.log.Infof("[network: %v, local: %v] stop listening", lsn.acceptor.Addr().Network(), lsn.acceptor.Addr())
+
+	// close acceptor and the serve loop will return
+	if err := lsn.acceptor.Close(); err != nil {
+		lsn.log.Errorf("[network: %v, local: %v] stop listening\r\n %v", lsn.acceptor.Addr().Network(), lsn.acceptor.Addr(), err)
+	}
+
+	lsn.log.Infof("[network: %v, local: %v] listen stopped", lsn.acceptor.Addr().Network(), lsn.acceptor.Addr())
+}
@@ -1,9 +1,10 @@
 package listener
 
 import (
-	"crypto/tls"
+	"context"
 	"net"
 	"ngio/channel"
+	"ngio/handler/ssl"
 	"ngio/logger"
 	"ngio/option"
 	"strings"
@@ -11,7 +12,7 @@ import (
 
 type TCPListener struct {
 	addr        *net.TCPAddr
-	listener    *net.TCPListener
+	listeners   []net.Listener
 	opts        *option.Options
 	log         logger.Logger
 	initializer channel.Initializer
@@ -27,9 +28,43 @@ func NewTCPListener(network, laddr string, opts *option.Options, initializer cha
 		return nil, option.ErrOptionIsNil
 	}
 
+	if err := option.ValidateTCPOptions(opts, true); err != nil {
+		return nil, err
+	}
+
 	return &TCPListener{
 		addr:        addr,
-		listener:    nil,
+		listeners:   nil,
+		opts:        opts,
+		log:         logger.DefaultLogger(),
+		initializer: initializer,
+	}, nil
+}
+
+// FromListener creates a TCPListener serving the connections accepted by an existing listener,
+// e.g. one from systemd socket activation or tls.Listen. Socket options are only applied to
+// accepted *net.TCPConn, the options of listening sockets are rejected with ErrListenOption.
+func FromListener(l net.Listener, opts *option.Options, initializer channel.Initializer) (*TCPListener, error) {
+	if l == nil {
+		return nil, ErrListenerIsNil
+	}
+
+	if opts == nil {
+		return nil, option.ErrOptionIsNil
+	}
+
+	if err := option.ValidateTCPOptions(opts, true); err != nil {
+		return nil, err
+	}
+
+	// the socket is already listening
+	if opts.Backlog > 0 || opts.ReusePort || opts.Acceptors > 1 || opts.TCPFastOpen > 0 || opts.TCPDeferAccept > 0 || opts.IPv6Only {
+		return nil, ErrListenOption
+	}
+
+	return &TCPListener{
+		addr:        nil,
+		listeners:   []net.Listener{l},
 		opts:        opts,
 		log:         logger.DefaultLogger(),
 		initializer: initializer,
@@ -37,47 +72,112 @@ func NewTCPListener(network, laddr string, opts *option.Options, initializer cha
 }
 
 func (lsn *TCPListener) Serve() error {
+	if lsn.listeners == nil {
+		if err := lsn.listen(); err != nil {
+			return err
+		}
+	}
+
+	// one accept loop per listening socket, the first failing loop shuts down the others
+	errC := make(chan error, len(lsn.listeners))
+
+	for _, l := range lsn.listeners {
+		lsn.log.Infof("[network: %v, local: %v] listening", l.Addr().Network(), l.Addr())
+
+		go func(l net.Listener) {
+			errC <- lsn.accept(l)
+		}(l)
+	}
+
+	var err error
+	for range lsn.listeners {
+		if acceptErr := <-errC; acceptErr != nil && err == nil {
+			err = acceptErr
+			lsn.Shutdown()
+		}
+	}
+
+	return err
+}
+
+func (lsn *TCPListener) listen() error {
 	if lsn.addr == nil {
 		return ErrBindAddrIsNil
 	}
 
-	listener, err := net.ListenTCP(lsn.addr.Network(), lsn.addr)
+	n, err := option.AcceptorCount(lsn.opts)
 	if err != nil {
 		return err
 	}
 
-	lsn.log.Infof("[network: %v, local: %v] listening", listener.Addr().Network(), listener.Addr())
+	if !option.NeedListenControl(lsn.opts) {
+		listener, err := net.ListenTCP(lsn.addr.Network(), lsn.addr)
+		if err != nil {
+			return err
+		}
+
+		lsn.listeners = []net.Listener{listener}
+		return lsn.setupListeners()
+	}
+
+	lc := net.ListenConfig{Control: option.ListenControl(lsn.opts)}
+	address := lsn.addr.String()
 
-	lsn.listener = listener
+	for i := 0; i < n; i++ {
+		listener, err := lc.Listen(context.Background(), lsn.addr.Network(), address)
+		if err != nil {
+			lsn.Shutdown()
+			return err
+		}
+
+		// the others must bind the port the first one got, in case it was 0
+		address = listener.Addr().String()
+		lsn.listeners = append(lsn.listeners, listener)
+	}
 
+	return lsn.setupListeners()
+}
+
+func (lsn *TCPListener) setupListeners() error {
+	for _, listener := range lsn.listeners {
+		if err := option.SetupTCPListenerOptions(listener.(*net.TCPListener), lsn.opts); err != nil {
+			lsn.log.Errorf("[network: %v, local: %v] set socket option\r\n %v", listener.Addr().Network(), listener.Addr(), err)
+			lsn.Shutdown()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (lsn *TCPListener) accept(listener net.Listener) error {
 	for {
-		conn, err := lsn.listener.AcceptTCP()
+		conn, err := listener.Accept()
 		if err != nil {
 			// forwardly close will return err "use of closed network connection"
 			if strings.Contains(err.Error(), "use of closed network connection") {
 				return nil
 			} else {
 				lsn.log.Errorf("tcp accept\r\n %v", err)
-				lsn.Shutdown()
 				return err
 			}
 		}
 
 		lsn.log.Debugf("[network: %v, remote: %v] accepted", conn.RemoteAddr().Network(), conn.RemoteAddr())
 
-		if err = option.SetupTCPOptions(conn, lsn.opts); err != nil {
-			lsn.log.Errorf("[network: %v, remote: %v] set socket option\r\n %v", conn.RemoteAddr().Network(), conn.RemoteAddr(), err)
-			if closeErr := conn.Close(); closeErr != nil {
-				lsn.log.Errorf("[network: %v, remote: %v] close\r\n %v", conn.RemoteAddr().Network(), conn.RemoteAddr(), closeErr)
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			if err = option.SetupTCPOptions(tcpConn, lsn.opts); err != nil {
+				lsn.log.Errorf("[network: %v, remote: %v] set socket option\r\n %v", conn.RemoteAddr().Network(), conn.RemoteAddr(), err)
+				if closeErr := conn.Close(); closeErr != nil {
+					lsn.log.Errorf("[network: %v, remote: %v] close\r\n %v", conn.RemoteAddr().Network(), conn.RemoteAddr(), closeErr)
+				}
+				continue
 			}
-			continue
 		}
 
-		var ch *channel.TCPChannel
+		ch := channel.NewTCPChannel(conn, lsn.opts.WriteDeadlinePeriod, lsn.opts.ReadDeadlinePeriod)
 		if lsn.opts.TLSConfig != nil {
-			ch = channel.NewTCPChannel(tls.Server(conn, lsn.opts.TLSConfig), lsn.opts.WriteDeadlinePeriod, lsn.opts.ReadDeadlinePeriod)
-		} else {
-			ch = channel.NewTCPChannel(conn, lsn.opts.WriteDeadlinePeriod, lsn.opts.ReadDeadlinePeriod)
+			ch.Pipeline().AddFirst("ssl", ssl.NewSslHandler(lsn.opts.TLSConfig, false, lsn.opts.TLSHandshakeTimeout))
 		}
 
 		if lsn.initializer != nil {
@@ -91,16 +191,19 @@ func (lsn *TCPListener) Serve() error {
 }
 
 func (lsn *TCPListener) Shutdown() {
-	if lsn.listener == nil {
-		return
-	}
+	for _, listener := range lsn.listeners {
+		lsn.log.Infof("[network: %v, local: %v] stop listening", listener.Addr().Network(), listener.Addr())
+
+		// close listener and the serve loop will return
+		if err := listener.Close(); err != nil {
+			// already closed by an other accept loop or a previous shutdown
+			if strings.Contains(err.Error(), "use of closed network connection") {
+				continue
+			}
 
-	lsn.log.Infof("[network: %v, local: %v] stop listening", lsn.listener.Addr().Network(), lsn.listener.Addr())
+			lsn.log.Errorf("[network: %v, local: %v] stop listening\r\n %v", listener.Addr().Network(), listener.Addr(), err)
+		}
 
-	// close listener and the serve loop will return
-	if err := lsn.listener.Close(); err != nil {
-		lsn.log.Errorf("[network: %v, local: %v] stop listening\r\n %v", lsn.listener.Addr().Network(), lsn.listener.Addr(), err)
+		lsn.log.Infof("[network: %v, local: %v] listen stopped", listener.Addr().Network(), listener.Addr())
 	}
-
-	lsn.log.Infof("[network: %v, local: %v] listen stopped", lsn.listener.Addr().Network(), lsn.listener.Addr())
 }
@@ -0,0 +1,75 @@
+package listener
+
+import (
+	"net"
+	"ngio/channel"
+	"ngio/option"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTCPListenerAcceptors(t *testing.T) {
+	opts := &option.Options{}
+	option.ReusePort(true).Apply(opts)
+	option.Acceptors(4).Apply(opts)
+
+	var accepted int32
+	lsn, err := NewTCPListener("tcp", "127.0.0.1:0", opts, func(ch channel.Channel) {
+		atomic.AddInt32(&accepted, 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lsn.listen(); err == option.ErrUnsupportedOption {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lsn.listeners) != 4 {
+		t.Fatalf("expected 4 listeners, got %d", len(lsn.listeners))
+	}
+
+	// all of them share the port the first one got
+	addr := lsn.listeners[0].Addr().String()
+	for _, l := range lsn.listeners[1:] {
+		if l.Addr().String() != addr {
+			t.Fatalf("expected %v, got %v", addr, l.Addr())
+		}
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- lsn.Serve()
+	}()
+
+	for i := 0; i < 16; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&accepted) != 16 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := atomic.LoadInt32(&accepted); n != 16 {
+		t.Fatalf("expected 16 accepted connections, got %d", n)
+	}
+
+	lsn.Shutdown()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for Serve to return")
+	}
+}
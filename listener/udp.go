@@ -1,6 +1,7 @@
 package listener
 
 import (
+	"context"
 	"net"
 	"ngio/channel"
 	"ngio/logger"
@@ -9,7 +10,9 @@ import (
 
 type UDPListener struct {
 	addr        *net.UDPAddr
-	ch          *channel.UDPChannel
+	conns       []*net.UDPConn
+	chs         []*channel.UDPChannel
+	demuxes     []*channel.UDPChildDemux
 	opts        *option.Options
 	log         logger.Logger
 	initializer channel.Initializer
@@ -25,9 +28,13 @@ func NewUDPListener(network, laddr string, opts *option.Options, initializer cha
 		return nil, option.ErrOptionIsNil
 	}
 
+	if err := option.ValidateUDPOptions(opts, true); err != nil {
+		return nil, err
+	}
+
 	return &UDPListener{
 		addr:        addr,
-		ch:          nil,
+		chs:         nil,
 		opts:        opts,
 		log:         logger.DefaultLogger(),
 		initializer: initializer,
@@ -35,47 +42,162 @@ func NewUDPListener(network, laddr string, opts *option.Options, initializer cha
 }
 
 func (lsn *UDPListener) Serve() error {
+	if lsn.conns == nil {
+		if err := lsn.listen(); err != nil {
+			return err
+		}
+	}
+
+	conns := lsn.conns
+
+	for _, conn := range conns {
+		lsn.log.Infof("[network: %v, local: %v] listening", conn.LocalAddr().Network(), conn.LocalAddr())
+
+		if err := option.SetupUDPOptions(conn, lsn.opts); err != nil {
+			lsn.log.Errorf("[network: %v, local: %v] set socket option\r\n %v", conn.LocalAddr().Network(), conn.LocalAddr(), err)
+			for _, c := range conns {
+				if closeErr := c.Close(); closeErr != nil {
+					lsn.log.Errorf("[network: %v, local: %v] close\r\n %v", c.LocalAddr().Network(), c.LocalAddr(), closeErr)
+				}
+			}
+			return err
+		}
+	}
+
+	for _, conn := range conns {
+		ch := channel.NewUDPChannel(conn, lsn.opts)
+
+		// every socket sharing the port must join to receive the groups' datagrams
+		if err := lsn.joinGroups(ch); err != nil {
+			lsn.log.Errorf("[network: %v, local: %v] join multicast group\r\n %v", conn.LocalAddr().Network(), conn.LocalAddr(), err)
+			for _, c := range conns {
+				if closeErr := c.Close(); closeErr != nil {
+					lsn.log.Errorf("[network: %v, local: %v] close\r\n %v", c.LocalAddr().Network(), c.LocalAddr(), closeErr)
+				}
+			}
+			return err
+		}
+
+		if lsn.opts.UDPChildChannels {
+			// the initializer sets up the pipelines of the peers' channels instead
+			demux := channel.NewUDPChildDemux(lsn.opts.UDPChildIdleTimeout, lsn.initializer)
+			ch.Pipeline().AddLast("udpChildDemux", demux)
+			lsn.demuxes = append(lsn.demuxes, demux)
+		} else if lsn.initializer != nil {
+			lsn.initializer(ch)
+		}
+
+		lsn.chs = append(lsn.chs, ch)
+	}
+
+	defer lsn.closeChildren()
+
+	if len(lsn.chs) == 1 {
+		return lsn.chs[0].Serve()
+	}
+
+	// each socket sharing the port is served by its own channel, the first failing one shuts down the others
+	errC := make(chan error, len(lsn.chs))
+
+	for _, ch := range lsn.chs {
+		go func(ch *channel.UDPChannel) {
+			errC <- ch.Serve()
+		}(ch)
+	}
+
+	var err error
+	for range lsn.chs {
+		if serveErr := <-errC; serveErr != nil && err == nil {
+			err = serveErr
+			lsn.Shutdown()
+		}
+	}
+
+	return err
+}
+
+func (lsn *UDPListener) joinGroups(ch *channel.UDPChannel) error {
+	for _, g := range lsn.opts.MulticastGroups {
+		var err error
+		if g.Source == nil {
+			err = ch.JoinGroup(g.Group, g.Interface)
+		} else {
+			err = ch.JoinSourceSpecificGroup(g.Group, g.Source, g.Interface)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		lsn.log.Infof("[network: %v, local: %v] joined multicast group %v", ch.LocalAddress().Network(), ch.LocalAddress(), g.Group)
+	}
+
+	return nil
+}
+
+func (lsn *UDPListener) closeChildren() {
+	for _, demux := range lsn.demuxes {
+		demux.CloseChildren()
+	}
+}
+
+func (lsn *UDPListener) listen() error {
 	if lsn.addr == nil {
 		return ErrBindAddrIsNil
 	}
 
-	conn, err := net.ListenUDP(lsn.addr.Network(), lsn.addr)
+	n, err := option.AcceptorCount(lsn.opts)
 	if err != nil {
 		return err
 	}
 
-	lsn.log.Infof("[network: %v, local: %v] listening", conn.LocalAddr().Network(), conn.LocalAddr())
-
-	if err := option.SetupUDPOptions(conn, lsn.opts); err != nil {
-		lsn.log.Errorf("[network: %v, local: %v] set socket option\r\n %v", conn.LocalAddr().Network(), conn.LocalAddr(), err)
-		if closeErr := conn.Close(); closeErr != nil {
-			lsn.log.Errorf("[network: %v, local: %v] close\r\n %v", conn.LocalAddr().Network(), conn.LocalAddr(), closeErr)
+	if !option.NeedListenControl(lsn.opts) {
+		conn, err := net.ListenUDP(lsn.addr.Network(), lsn.addr)
+		if err != nil {
+			return err
 		}
-		return err
+
+		lsn.conns = []*net.UDPConn{conn}
+		return nil
 	}
 
-	lsn.ch = channel.NewUDPChannel(conn)
-	if lsn.initializer != nil {
-		lsn.initializer(lsn.ch)
+	lc := net.ListenConfig{Control: option.ListenControl(lsn.opts)}
+	address := lsn.addr.String()
+	conns := make([]*net.UDPConn, 0, n)
+
+	for i := 0; i < n; i++ {
+		conn, err := lc.ListenPacket(context.Background(), lsn.addr.Network(), address)
+		if err != nil {
+			for _, c := range conns {
+				_ = c.Close()
+			}
+			return err
+		}
+
+		// the others must bind the port the first one got, in case it was 0
+		address = conn.LocalAddr().String()
+		conns = append(conns, conn.(*net.UDPConn))
 	}
 
-	return lsn.ch.Serve()
+	lsn.conns = conns
+	return nil
 }
 
 func (lsn *UDPListener) Shutdown() {
-	if lsn.ch == nil {
-		return
-	}
-
-	if !lsn.ch.IsActive() {
-		lsn.log.Warn("close udp listener repeated")
+	if lsn.chs == nil {
 		return
 	}
 
-	lsn.log.Infof("[network: %v, local: %v] stop listening", lsn.ch.LocalAddress().Network(), lsn.ch.LocalAddress())
+	for _, ch := range lsn.chs {
+		if !ch.IsActive() {
+			lsn.log.Warn("close udp listener repeated")
+			continue
+		}
 
-	lsn.ch.Close()
+		lsn.log.Infof("[network: %v, local: %v] stop listening", ch.LocalAddress().Network(), ch.LocalAddress())
 
-	lsn.log.Infof("[network: %v, local: %v] listen stopped", lsn.ch.LocalAddress().Network(), lsn.ch.LocalAddress())
+		ch.Close()
 
+		lsn.log.Infof("[network: %v, local: %v] listen stopped", ch.LocalAddress().Network(), ch.LocalAddress())
+	}
 }
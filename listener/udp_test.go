@@ -0,0 +1,116 @@
+package listener
+
+import (
+	"net"
+	"ngio/channel"
+	"ngio/option"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countHandler struct {
+	read *int32
+}
+
+func (handler countHandler) ChannelRead(ctx *channel.Context, msg interface{}) {
+	atomic.AddInt32(handler.read, 1)
+}
+
+func newUDPAcceptors(t *testing.T, read *int32) *UDPListener {
+	opts := &option.Options{}
+	option.ReusePort(true).Apply(opts)
+	option.Acceptors(4).Apply(opts)
+
+	lsn, err := NewUDPListener("udp4", "127.0.0.1:0", opts, func(ch channel.Channel) {
+		ch.Pipeline().AddLast("count", countHandler{read: read})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lsn.listen(); err == option.ErrUnsupportedOption {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lsn.conns) != 4 {
+		t.Fatalf("expected 4 sockets, got %d", len(lsn.conns))
+	}
+
+	// all of them share the port the first one got
+	addr := lsn.conns[0].LocalAddr().String()
+	for _, c := range lsn.conns[1:] {
+		if c.LocalAddr().String() != addr {
+			t.Fatalf("expected %v, got %v", addr, c.LocalAddr())
+		}
+	}
+
+	return lsn
+}
+
+func TestUDPListenerAcceptors(t *testing.T) {
+	var read int32
+	lsn := newUDPAcceptors(t, &read)
+	addr := lsn.conns[0].LocalAddr().String()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- lsn.Serve()
+	}()
+
+	// each peer's datagrams are spread over the sockets by the kernel
+	deadline := time.Now().Add(time.Second)
+	for i := 0; i < 16; i++ {
+		conn, err := net.Dial("udp4", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		for atomic.LoadInt32(&read) <= int32(i) && time.Now().Before(deadline) {
+			_, _ = conn.Write([]byte("ping"))
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if n := atomic.LoadInt32(&read); n < 16 {
+		t.Fatalf("expected a datagram of each of the 16 peers, got %d", n)
+	}
+
+	lsn.Shutdown()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for Serve to return")
+	}
+}
+
+func TestUDPListenerAcceptorFailure(t *testing.T) {
+	var read int32
+	lsn := newUDPAcceptors(t, &read)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- lsn.Serve()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	// one socket failing shuts down the others
+	_ = lsn.conns[1].Close()
+
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Fatal("expected the error of the failed socket")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for Serve to return")
+	}
+}
@@ -0,0 +1,138 @@
+package option
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+)
+
+var (
+	ErrReusePortRequired  = errors.New("option: more than one acceptor requires ReusePort")
+	ErrUnsupportedOption  = errors.New("option: unsupported on this platform")
+	ErrKeepAliveRequired  = errors.New("option: TCPKeepIdle, TCPKeepInterval and TCPKeepCount require TCPKeepAlive")
+	ErrServerOnlyOption   = errors.New("option: TCPFastOpen, TCPDeferAccept, IPv6Only, Backlog, Acceptors, JoinGroup and UDPChildChannels only apply to servers")
+	ErrClientOnlyOption   = errors.New("option: TCPFastOpenConnect and Proxy only apply to clients")
+	ErrIPv6Required       = errors.New("option: IPv6Only requires an IPv6 network")
+	ErrNoInterfaceAddress = errors.New("option: the multicast interface has no IPv4 address")
+	ErrTCPOnlyOption      = errors.New("option: TCPFastOpen, TCPFastOpenConnect, TCPDeferAccept, TCPKeepIdle, TCPKeepInterval, TCPKeepCount, TCPUserTimeout, TCPQuickAck, TCPCork, Backlog and Proxy only apply to TCP")
+)
+
+// ValidateTCPOptions reports the combinations of opts which can not be applied to
+// a server's (isServer) or a client's TCP sockets.
+func ValidateTCPOptions(opts *Options, isServer bool) error {
+	if !opts.TCPKeepAlive && (opts.TCPKeepIdle > 0 || opts.TCPKeepInterval > 0 || opts.TCPKeepCount > 0) {
+		return ErrKeepAliveRequired
+	}
+
+	if isServer && (opts.TCPFastOpenConnect || opts.Proxy != nil) {
+		return ErrClientOnlyOption
+	}
+
+	if !isServer && (opts.TCPFastOpen > 0 || opts.TCPDeferAccept > 0 || opts.IPv6Only || opts.Backlog > 0 || opts.Acceptors > 1) {
+		return ErrServerOnlyOption
+	}
+
+	return nil
+}
+
+// ValidateUDPOptions reports the combinations of opts which can not be applied to
+// a server's (isServer) or a client's UDP sockets.
+func ValidateUDPOptions(opts *Options, isServer bool) error {
+	if opts.TCPFastOpen > 0 || opts.TCPFastOpenConnect || opts.TCPDeferAccept > 0 || opts.Backlog > 0 || opts.Proxy != nil {
+		return ErrTCPOnlyOption
+	}
+
+	if opts.TCPKeepIdle > 0 || opts.TCPKeepInterval > 0 || opts.TCPKeepCount > 0 || opts.TCPUserTimeout > 0 || opts.TCPQuickAck || opts.TCPCork {
+		return ErrTCPOnlyOption
+	}
+
+	if !isServer && (opts.IPv6Only || opts.Acceptors > 1 || len(opts.MulticastGroups) > 0 || opts.UDPChildChannels) {
+		return ErrServerOnlyOption
+	}
+
+	return nil
+}
+
+// ListenControl returns the function net.ListenConfig calls to set the socket options of a
+// listening socket before it is bound.
+func ListenControl(opts *Options) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if opts.IPv6Only && !strings.HasSuffix(network, "6") {
+			return ErrIPv6Required
+		}
+
+		return Control(c, func(fd uintptr) error {
+			return setListenSockopts(fd, network, opts)
+		})
+	}
+}
+
+// DialControl returns the function net.Dialer calls to set the socket options of a
+// dialing socket before it connects.
+func DialControl(opts *Options) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return Control(c, func(fd uintptr) error {
+			return setDialSockopts(fd, network, opts)
+		})
+	}
+}
+
+// NeedListenControl reports whether listening sockets need ListenControl,
+// otherwise the plain net.ListenTCP and net.ListenUDP are enough.
+func NeedListenControl(opts *Options) bool {
+	return opts.ReusePort || opts.TCPFastOpen > 0 || opts.TCPDeferAccept > 0 || opts.IPv6Only
+}
+
+// NeedDialControl reports whether dialing sockets need DialControl,
+// otherwise the plain net.DialTCP is enough.
+func NeedDialControl(opts *Options) bool {
+	return opts.TCPFastOpenConnect
+}
+
+// AcceptorCount returns how many sockets a server should listen on, at least one.
+func AcceptorCount(opts *Options) (int, error) {
+	if opts.Acceptors <= 1 {
+		return 1, nil
+	}
+
+	if !opts.ReusePort {
+		return 0, ErrReusePortRequired
+	}
+
+	return opts.Acceptors, nil
+}
+
+func needConnControl(opts *Options) bool {
+	return opts.TCPKeepIdle > 0 || opts.TCPKeepInterval > 0 || opts.TCPKeepCount > 0 ||
+		opts.TCPUserTimeout > 0 || opts.TCPQuickAck || opts.TCPCork || opts.IPTOS != nil || opts.IPTTL != nil
+}
+
+func needUDPControl(opts *Options) bool {
+	return opts.UDPBroadcast || opts.MulticastTTL != nil || opts.MulticastLoopback != nil ||
+		opts.MulticastInterface != nil || opts.UDPPacketInfo || opts.IPTOS != nil || opts.IPTTL != nil
+}
+
+// Control runs f with the file descriptor of c, returning the error of either.
+func Control(c syscall.RawConn, f func(fd uintptr) error) error {
+	var sockErr error
+
+	if err := c.Control(func(fd uintptr) {
+		sockErr = f(fd)
+	}); err != nil {
+		return err
+	}
+
+	return sockErr
+}
+
+func isIPv6(addr net.Addr) bool {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP.To4() == nil
+	case *net.UDPAddr:
+		return a.IP.To4() == nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,89 @@
+package option
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func optionsOf(opts ...Option) *Options {
+	o := &Options{}
+	for _, opt := range opts {
+		opt.Apply(o)
+	}
+
+	return o
+}
+
+func TestValidateTCPOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *Options
+		isServer bool
+		err      error
+	}{
+		{"defaults server", optionsOf(), true, nil},
+		{"defaults client", optionsOf(), false, nil},
+		{"keepalive tuning", optionsOf(TCPKeepAlive(true), TCPKeepIdle(time.Minute), TCPKeepCount(3)), false, nil},
+		{"keepalive tuning without keepalive", optionsOf(TCPKeepInterval(time.Second)), true, ErrKeepAliveRequired},
+		{"fast open on server", optionsOf(TCPFastOpen(16), TCPDeferAccept(time.Second), Backlog(128)), true, nil},
+		{"fast open on client", optionsOf(TCPFastOpen(16)), false, ErrServerOnlyOption},
+		{"defer accept on client", optionsOf(TCPDeferAccept(time.Second)), false, ErrServerOnlyOption},
+		{"ipv6 only on client", optionsOf(IPv6Only(true)), false, ErrServerOnlyOption},
+		{"backlog on client", optionsOf(Backlog(128)), false, ErrServerOnlyOption},
+		{"acceptors on client", optionsOf(ReusePort(true), Acceptors(4)), false, ErrServerOnlyOption},
+		{"fast open connect on client", optionsOf(TCPFastOpenConnect(true)), false, nil},
+		{"fast open connect on server", optionsOf(TCPFastOpenConnect(true)), true, ErrClientOnlyOption},
+		{"proxy on server", optionsOf(Proxy(ProxyConfig{Protocol: ProxySOCKS5, Address: "127.0.0.1:1080"})), true, ErrClientOnlyOption},
+	}
+
+	for _, test := range tests {
+		if err := ValidateTCPOptions(test.opts, test.isServer); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestValidateUDPOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *Options
+		isServer bool
+		err      error
+	}{
+		{"defaults server", optionsOf(), true, nil},
+		{"defaults client", optionsOf(), false, nil},
+		{"broadcast on client", optionsOf(UDPBroadcast(true), IPTTL(64)), false, nil},
+		{"groups on server", optionsOf(JoinGroup(net.IPv4(239, 0, 0, 1), nil), UDPChildChannels(time.Minute)), true, nil},
+		{"groups on client", optionsOf(JoinGroup(net.IPv4(239, 0, 0, 1), nil)), false, ErrServerOnlyOption},
+		{"children on client", optionsOf(UDPChildChannels(time.Minute)), false, ErrServerOnlyOption},
+		{"backlog on server", optionsOf(Backlog(128)), true, ErrTCPOnlyOption},
+		{"fast open connect on client", optionsOf(TCPFastOpenConnect(true)), false, ErrTCPOnlyOption},
+		{"proxy on client", optionsOf(Proxy(ProxyConfig{Protocol: ProxySOCKS5, Address: "127.0.0.1:1080"})), false, ErrTCPOnlyOption},
+		{"keepalive tuning", optionsOf(TCPKeepAlive(true), TCPKeepIdle(time.Minute)), true, ErrTCPOnlyOption},
+		{"user timeout", optionsOf(TCPUserTimeout(time.Second)), false, ErrTCPOnlyOption},
+		{"quick ack", optionsOf(TCPQuickAck(true)), true, ErrTCPOnlyOption},
+		{"cork", optionsOf(TCPCork(true)), false, ErrTCPOnlyOption},
+	}
+
+	for _, test := range tests {
+		if err := ValidateUDPOptions(test.opts, test.isServer); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestIPTOSZero(t *testing.T) {
+	opts := optionsOf(IPTOS(0))
+	if opts.IPTOS == nil || *opts.IPTOS != 0 || !needConnControl(opts) {
+		t.Fatal("expected IPTOS 0 to be set")
+	}
+
+	if needConnControl(optionsOf()) || needUDPControl(optionsOf()) {
+		t.Fatal("expected no socket options by default")
+	}
+
+	if opts := optionsOf(MulticastTTL(0)); !needUDPControl(opts) || *opts.MulticastTTL != 0 {
+		t.Fatal("expected MulticastTTL 0 to be set")
+	}
+}
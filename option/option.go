@@ -3,6 +3,8 @@ package option
 import (
 	"crypto/tls"
 	"errors"
+	"net"
+	"net/http"
 	"time"
 )
 
@@ -10,6 +12,63 @@ var (
 	ErrOptionIsNil = errors.New("option is nil")
 )
 
+// MulticastGroup is a multicast group a UDP server joins on startup.
+// Source is only set for source-specific groups, a nil Interface lets the kernel choose one.
+type MulticastGroup struct {
+	Group     net.IP
+	Source    net.IP
+	Interface *net.Interface
+}
+
+// UDPDispatchMode is how a UDP channel hands received datagrams to its pipeline.
+type UDPDispatchMode int
+
+const (
+	// UDPDispatchGoroutine runs the pipeline on a new goroutine per datagram, in no particular order.
+	// It is the default, as before UDPDispatch existed, and only bounded if UDPQueueSize is set.
+	UDPDispatchGoroutine UDPDispatchMode = iota
+	// UDPDispatchSerial runs the pipeline on one goroutine, in arrival order.
+	UDPDispatchSerial
+	// UDPDispatchWorkers runs the pipeline on a pool of UDPWorkers goroutines, in no particular order.
+	UDPDispatchWorkers
+	// UDPDispatchPerPeer runs the pipeline on UDPWorkers goroutines, each remote address always
+	// on the same one, so datagrams of a peer keep their order.
+	UDPDispatchPerPeer
+)
+
+// UDPOverflowPolicy is what a UDP channel does with a datagram when the dispatch queue is full,
+// or UDPQueueSize goroutines of UDPDispatchGoroutine are running. It applies to every dispatch mode.
+type UDPOverflowPolicy int
+
+const (
+	// UDPOverflowDrop drops the datagram and counts it, see channel.UDPChannel.Dropped.
+	UDPOverflowDrop UDPOverflowPolicy = iota
+	// UDPOverflowBlock stops reading until there is room, leaving the kernel to drop datagrams.
+	UDPOverflowBlock
+)
+
+// ProxyProtocol is the protocol a client talks to its proxy.
+type ProxyProtocol int
+
+const (
+	ProxySOCKS5 ProxyProtocol = iota
+	ProxySOCKS4a
+	// ProxyHTTP tunnels through an HTTP proxy with the CONNECT method.
+	ProxyHTTP
+)
+
+// ProxyConfig is the proxy a client connects to its destination through. With SOCKS4a Username
+// is sent as user id and Password is ignored, without Username SOCKS5 and HTTP skip authentication.
+// Header is sent along with the CONNECT request of an HTTP proxy.
+type ProxyConfig struct {
+	Protocol       ProxyProtocol
+	Address        string
+	Username       string
+	Password       string
+	Header         http.Header
+	ConnectTimeout time.Duration
+}
+
 type Options struct {
 	TCPKeepAlive        bool
 	TCPKeepAlivePeriod  time.Duration
@@ -20,6 +79,37 @@ type Options struct {
 	ReadDeadlinePeriod  time.Duration
 	WriteDeadlinePeriod time.Duration
 	TLSConfig           *tls.Config
+	TLSHandshakeTimeout time.Duration
+	ReusePort           bool
+	Acceptors           int
+	TCPKeepIdle         time.Duration
+	TCPKeepInterval     time.Duration
+	TCPKeepCount        int
+	TCPUserTimeout      time.Duration
+	TCPQuickAck         bool
+	TCPFastOpen         int
+	TCPFastOpenConnect  bool
+	TCPDeferAccept      time.Duration
+	TCPCork             bool
+	IPTOS               *int
+	IPTTL               *int
+	IPv6Only            bool
+	Backlog             int
+	UDPBroadcast        bool
+	MulticastTTL        *int
+	MulticastLoopback   *bool
+	MulticastInterface  *net.Interface
+	UDPPacketInfo       bool
+	MulticastGroups     []MulticastGroup
+	UDPChildChannels    bool
+	UDPChildIdleTimeout time.Duration
+	UDPDispatch         UDPDispatchMode
+	UDPWorkers          int
+	UDPQueueSize        int
+	UDPOverflow         UDPOverflowPolicy
+	UDPMaxDatagramSize  int
+	UDPBatchSize        int
+	Proxy               *ProxyConfig
 }
 
 type Option interface {
@@ -80,14 +170,248 @@ func WriteDeadlinePeriod(d time.Duration) Option {
 	})
 }
 
+// ReadDeadlinePeriod closes a connection which read nothing for d, after firing channel.ErrReadTimeout.
+// timeout.IdleStateHandler lets the handlers decide instead, e.g. to send a heartbeat first.
 func ReadDeadlinePeriod(d time.Duration) Option {
 	return newOptionFunc(func(o *Options) {
 		o.ReadDeadlinePeriod = d
 	})
 }
 
+// TLS secures the connections with an SslHandler. The protocol negotiated out of tlsConfig.NextProtos
+// is picked up by an ssl.ApplicationProtocolNegotiationHandler.
 func TLS(tlsConfig *tls.Config) Option {
 	return newOptionFunc(func(o *Options) {
 		o.TLSConfig = tlsConfig
 	})
 }
+
+// TLSHandshakeTimeout sets how long the TLS handshake of a connection may take before it is closed.
+// Zero means no limit.
+func TLSHandshakeTimeout(d time.Duration) Option {
+	return newOptionFunc(func(o *Options) {
+		o.TLSHandshakeTimeout = d
+	})
+}
+
+// ReusePort sets SO_REUSEPORT on listening sockets, so several sockets can bind the same address.
+func ReusePort(reusePort bool) Option {
+	return newOptionFunc(func(o *Options) {
+		o.ReusePort = reusePort
+	})
+}
+
+// Acceptors sets how many sockets a server listens on the same address, each one served by its
+// own accept loop (TCP) or channel (UDP) and load-balanced by the kernel. More than one requires ReusePort.
+func Acceptors(n int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.Acceptors = n
+	})
+}
+
+// TCPKeepIdle sets TCP_KEEPIDLE, the idle time before the first keepalive probe. Requires TCPKeepAlive.
+func TCPKeepIdle(d time.Duration) Option {
+	return newOptionFunc(func(o *Options) {
+		o.TCPKeepIdle = d
+	})
+}
+
+// TCPKeepInterval sets TCP_KEEPINTVL, the time between keepalive probes. Requires TCPKeepAlive.
+func TCPKeepInterval(d time.Duration) Option {
+	return newOptionFunc(func(o *Options) {
+		o.TCPKeepInterval = d
+	})
+}
+
+// TCPKeepCount sets TCP_KEEPCNT, the number of unanswered probes before the connection is dropped. Requires TCPKeepAlive.
+func TCPKeepCount(n int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.TCPKeepCount = n
+	})
+}
+
+// TCPUserTimeout sets TCP_USER_TIMEOUT, how long written data may stay unacknowledged before the connection is dropped.
+func TCPUserTimeout(d time.Duration) Option {
+	return newOptionFunc(func(o *Options) {
+		o.TCPUserTimeout = d
+	})
+}
+
+// TCPQuickAck sets TCP_QUICKACK once the connection is set up. The kernel may leave quickack mode later on.
+func TCPQuickAck(quickAck bool) Option {
+	return newOptionFunc(func(o *Options) {
+		o.TCPQuickAck = quickAck
+	})
+}
+
+// TCPFastOpen sets TCP_FASTOPEN on listening sockets, with the given queue length of pending fast open requests. Server only.
+func TCPFastOpen(queueLength int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.TCPFastOpen = queueLength
+	})
+}
+
+// TCPFastOpenConnect sets TCP_FASTOPEN_CONNECT on dialed sockets. Client only.
+func TCPFastOpenConnect(fastOpen bool) Option {
+	return newOptionFunc(func(o *Options) {
+		o.TCPFastOpenConnect = fastOpen
+	})
+}
+
+// TCPDeferAccept sets TCP_DEFER_ACCEPT on listening sockets, connections are only accepted once data arrived. Server only.
+func TCPDeferAccept(d time.Duration) Option {
+	return newOptionFunc(func(o *Options) {
+		o.TCPDeferAccept = d
+	})
+}
+
+// TCPCork sets TCP_CORK, partial frames are held back until the cork is removed or 200ms passed.
+func TCPCork(cork bool) Option {
+	return newOptionFunc(func(o *Options) {
+		o.TCPCork = cork
+	})
+}
+
+// IPTOS sets IP_TOS, or IPV6_TCLASS on IPv6 sockets. The kernel default is left alone unless set, even to 0.
+func IPTOS(tos int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.IPTOS = &tos
+	})
+}
+
+// IPTTL sets IP_TTL, or IPV6_UNICAST_HOPS on IPv6 sockets. The kernel default is left alone unless set.
+func IPTTL(ttl int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.IPTTL = &ttl
+	})
+}
+
+// IPv6Only sets IPV6_V6ONLY on listening sockets, so a "tcp6" or "udp6" server does not accept IPv4 peers. Server only.
+func IPv6Only(v6Only bool) Option {
+	return newOptionFunc(func(o *Options) {
+		o.IPv6Only = v6Only
+	})
+}
+
+// Backlog sets the length of the queue of accepted connections of listening sockets. Server only.
+func Backlog(n int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.Backlog = n
+	})
+}
+
+// UDPBroadcast sets SO_BROADCAST, allowing datagrams to be sent to broadcast addresses.
+func UDPBroadcast(broadcast bool) Option {
+	return newOptionFunc(func(o *Options) {
+		o.UDPBroadcast = broadcast
+	})
+}
+
+// MulticastTTL sets IP_MULTICAST_TTL, or IPV6_MULTICAST_HOPS on IPv6 sockets. The kernel default is
+// left alone unless set, even to 0, which keeps datagrams on the local host.
+func MulticastTTL(ttl int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.MulticastTTL = &ttl
+	})
+}
+
+// MulticastLoopback sets IP_MULTICAST_LOOP, or IPV6_MULTICAST_LOOP on IPv6 sockets.
+// The kernel loops multicast datagrams back by default.
+func MulticastLoopback(loopback bool) Option {
+	return newOptionFunc(func(o *Options) {
+		o.MulticastLoopback = &loopback
+	})
+}
+
+// MulticastInterface sets IP_MULTICAST_IF, or IPV6_MULTICAST_IF on IPv6 sockets, the interface multicast datagrams are sent on.
+func MulticastInterface(ifi *net.Interface) Option {
+	return newOptionFunc(func(o *Options) {
+		o.MulticastInterface = ifi
+	})
+}
+
+// UDPPacketInfo sets IP_PKTINFO, or IPV6_RECVPKTINFO on IPv6 sockets. Received buffer.DatagramPacket
+// then carry the local address they arrived on, and replies to them are sent from that address.
+func UDPPacketInfo(packetInfo bool) Option {
+	return newOptionFunc(func(o *Options) {
+		o.UDPPacketInfo = packetInfo
+	})
+}
+
+// JoinGroup makes a UDP server join the multicast group on ifi on startup. It may be set several times.
+func JoinGroup(group net.IP, ifi *net.Interface) Option {
+	return newOptionFunc(func(o *Options) {
+		o.MulticastGroups = append(o.MulticastGroups, MulticastGroup{Group: group, Interface: ifi})
+	})
+}
+
+// JoinSourceSpecificGroup makes a UDP server join the multicast group on ifi on startup,
+// only receiving the datagrams sent by source. It may be set several times.
+func JoinSourceSpecificGroup(group, source net.IP, ifi *net.Interface) Option {
+	return newOptionFunc(func(o *Options) {
+		o.MulticastGroups = append(o.MulticastGroups, MulticastGroup{Group: group, Source: source, Interface: ifi})
+	})
+}
+
+// UDPChildChannels makes a UDP server demultiplex datagrams by remote address into one child channel
+// per peer, each one created by the initializer on the peer's first datagram and closed after
+// idleTimeout without reads or writes. A zero idleTimeout keeps children until the server shuts down.
+func UDPChildChannels(idleTimeout time.Duration) Option {
+	return newOptionFunc(func(o *Options) {
+		o.UDPChildChannels = true
+		o.UDPChildIdleTimeout = idleTimeout
+	})
+}
+
+// UDPDispatch sets how received datagrams are handed to the pipeline, UDPDispatchGoroutine by default.
+func UDPDispatch(mode UDPDispatchMode) Option {
+	return newOptionFunc(func(o *Options) {
+		o.UDPDispatch = mode
+	})
+}
+
+// UDPWorkers sets the number of goroutines of UDPDispatchWorkers and UDPDispatchPerPeer, the number of CPUs by default.
+func UDPWorkers(n int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.UDPWorkers = n
+	})
+}
+
+// UDPQueueSize sets how many datagrams may wait for a worker, per queue, 1024 by default. With UDPDispatchGoroutine
+// it sets how many goroutines may run at once, unlimited by default.
+func UDPQueueSize(n int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.UDPQueueSize = n
+	})
+}
+
+// UDPOverflow sets what happens to a datagram when its dispatch queue is full, UDPOverflowDrop by default.
+func UDPOverflow(policy UDPOverflowPolicy) Option {
+	return newOptionFunc(func(o *Options) {
+		o.UDPOverflow = policy
+	})
+}
+
+// UDPMaxDatagramSize sets the size of the reused buffers datagrams are read into, larger ones are truncated.
+// Each datagram is then copied into a buffer of its own size. 65535 by default.
+func UDPMaxDatagramSize(size int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.UDPMaxDatagramSize = size
+	})
+}
+
+// UDPBatchSize sets how many datagrams are read or written per syscall with recvmmsg and sendmmsg.
+// Only linux/amd64 and linux/arm64 support batches, elsewhere datagrams are read and written one at a time.
+func UDPBatchSize(n int) Option {
+	return newOptionFunc(func(o *Options) {
+		o.UDPBatchSize = n
+	})
+}
+
+// Proxy makes a client connect through a proxy, which connects to the client's address.
+// The address is resolved by the proxy.
+func Proxy(config ProxyConfig) Option {
+	return newOptionFunc(func(o *Options) {
+		o.Proxy = &config
+	})
+}
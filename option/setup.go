@@ -40,6 +40,38 @@ func SetupTCPOptions(conn *net.TCPConn, opts *Options) (err error) {
 		}
 	}
 
+	if needConnControl(opts) {
+		raw, rawErr := conn.SyscallConn()
+		if rawErr != nil {
+			return rawErr
+		}
+
+		ipv6 := isIPv6(conn.LocalAddr())
+		if err = Control(raw, func(fd uintptr) error {
+			return setConnSockopts(fd, ipv6, opts)
+		}); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// SetupTCPListenerOptions applies the options which can only be set once the socket is listening.
+func SetupTCPListenerOptions(l *net.TCPListener, opts *Options) (err error) {
+	if opts.Backlog > 0 {
+		raw, rawErr := l.SyscallConn()
+		if rawErr != nil {
+			return rawErr
+		}
+
+		if err = Control(raw, func(fd uintptr) error {
+			return setListenBacklog(fd, opts.Backlog)
+		}); err != nil {
+			return
+		}
+	}
+
 	return
 }
 
@@ -56,5 +88,19 @@ func SetupUDPOptions(conn *net.UDPConn, opts *Options) (err error) {
 		}
 	}
 
+	if needUDPControl(opts) {
+		raw, rawErr := conn.SyscallConn()
+		if rawErr != nil {
+			return rawErr
+		}
+
+		ipv6 := isIPv6(conn.LocalAddr())
+		if err = Control(raw, func(fd uintptr) error {
+			return setUDPSockopts(fd, ipv6, opts)
+		}); err != nil {
+			return
+		}
+	}
+
 	return
 }
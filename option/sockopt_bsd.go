@@ -0,0 +1,149 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package option
+
+import (
+	"net"
+	"syscall"
+)
+
+func setListenSockopts(fd uintptr, network string, opts *Options) error {
+	if opts.TCPFastOpen > 0 || opts.TCPDeferAccept > 0 || opts.IPv6Only {
+		return ErrUnsupportedOption
+	}
+
+	if opts.ReusePort {
+		return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEPORT, 1)
+	}
+
+	return nil
+}
+
+func setDialSockopts(fd uintptr, network string, opts *Options) error {
+	if opts.TCPFastOpenConnect {
+		return ErrUnsupportedOption
+	}
+
+	return nil
+}
+
+func setConnSockopts(fd uintptr, ipv6 bool, opts *Options) error {
+	if needConnControl(opts) {
+		return ErrUnsupportedOption
+	}
+
+	return nil
+}
+
+func setUDPSockopts(fd uintptr, ipv6 bool, opts *Options) (err error) {
+	s := int(fd)
+
+	// IP_PKTINFO is linux only, the BSDs have IP_RECVDSTADDR instead
+	if opts.UDPPacketInfo {
+		return ErrUnsupportedOption
+	}
+
+	if opts.UDPBroadcast {
+		if err = syscall.SetsockoptInt(s, syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1); err != nil {
+			return
+		}
+	}
+
+	// the IPv4 multicast options take an u_char here
+	if opts.MulticastTTL != nil {
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS, *opts.MulticastTTL)
+		} else {
+			err = syscall.SetsockoptByte(s, syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, byte(*opts.MulticastTTL))
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	if opts.MulticastLoopback != nil {
+		loop := 0
+		if *opts.MulticastLoopback {
+			loop = 1
+		}
+
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_LOOP, loop)
+		} else {
+			err = syscall.SetsockoptByte(s, syscall.IPPROTO_IP, syscall.IP_MULTICAST_LOOP, byte(loop))
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	if opts.MulticastInterface != nil {
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_IF, opts.MulticastInterface.Index)
+		} else {
+			err = setMulticastInterface4(s, opts.MulticastInterface)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return setIPSockopts(s, ipv6, opts)
+}
+
+// setIPSockopts sets the IP level options of UDP sockets.
+func setIPSockopts(s int, ipv6 bool, opts *Options) (err error) {
+	if opts.IPTOS != nil {
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, *opts.IPTOS)
+		} else {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_TOS, *opts.IPTOS)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	if opts.IPTTL != nil {
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, *opts.IPTTL)
+		} else {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_TTL, *opts.IPTTL)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// setMulticastInterface4 sets IP_MULTICAST_IF, which takes an address of the interface here.
+func setMulticastInterface4(s int, ifi *net.Interface) error {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			if ip4 := ipNet.IP.To4(); ip4 != nil {
+				var a [4]byte
+				copy(a[:], ip4)
+				return syscall.SetsockoptInet4Addr(s, syscall.IPPROTO_IP, syscall.IP_MULTICAST_IF, a)
+			}
+		}
+	}
+
+	return ErrNoInterfaceAddress
+}
+
+func setListenBacklog(fd uintptr, backlog int) error {
+	return ErrUnsupportedOption
+}
@@ -0,0 +1,200 @@
+package option
+
+import (
+	"syscall"
+	"time"
+)
+
+// not exported by syscall on linux.
+const (
+	soReusePort        = 0xf
+	tcpUserTimeout     = 0x12
+	tcpFastOpen        = 0x17
+	tcpFastOpenConnect = 0x1e
+)
+
+func setListenSockopts(fd uintptr, network string, opts *Options) (err error) {
+	s := int(fd)
+
+	if opts.ReusePort {
+		if err = syscall.SetsockoptInt(s, syscall.SOL_SOCKET, soReusePort, 1); err != nil {
+			return
+		}
+	}
+
+	if opts.TCPFastOpen > 0 {
+		if err = syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, tcpFastOpen, opts.TCPFastOpen); err != nil {
+			return
+		}
+	}
+
+	if opts.TCPDeferAccept > 0 {
+		if err = syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, syscall.TCP_DEFER_ACCEPT, seconds(opts.TCPDeferAccept)); err != nil {
+			return
+		}
+	}
+
+	if opts.IPv6Only {
+		if err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, 1); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func setDialSockopts(fd uintptr, network string, opts *Options) (err error) {
+	if opts.TCPFastOpenConnect {
+		if err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpFastOpenConnect, 1); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func setConnSockopts(fd uintptr, ipv6 bool, opts *Options) (err error) {
+	s := int(fd)
+
+	if opts.TCPKeepIdle > 0 {
+		if err = syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE, seconds(opts.TCPKeepIdle)); err != nil {
+			return
+		}
+	}
+
+	if opts.TCPKeepInterval > 0 {
+		if err = syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, seconds(opts.TCPKeepInterval)); err != nil {
+			return
+		}
+	}
+
+	if opts.TCPKeepCount > 0 {
+		if err = syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, opts.TCPKeepCount); err != nil {
+			return
+		}
+	}
+
+	if opts.TCPUserTimeout > 0 {
+		if err = syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, tcpUserTimeout, int(opts.TCPUserTimeout/time.Millisecond)); err != nil {
+			return
+		}
+	}
+
+	if opts.TCPQuickAck {
+		if err = syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, syscall.TCP_QUICKACK, 1); err != nil {
+			return
+		}
+	}
+
+	if opts.TCPCork {
+		if err = syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, syscall.TCP_CORK, 1); err != nil {
+			return
+		}
+	}
+
+	return setIPSockopts(s, ipv6, opts)
+}
+
+// setIPSockopts sets the IP level options shared by TCP and UDP sockets.
+func setIPSockopts(s int, ipv6 bool, opts *Options) (err error) {
+	if opts.IPTOS != nil {
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, *opts.IPTOS)
+		} else {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_TOS, *opts.IPTOS)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	if opts.IPTTL != nil {
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, *opts.IPTTL)
+		} else {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_TTL, *opts.IPTTL)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func setUDPSockopts(fd uintptr, ipv6 bool, opts *Options) (err error) {
+	s := int(fd)
+
+	if opts.UDPBroadcast {
+		if err = syscall.SetsockoptInt(s, syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1); err != nil {
+			return
+		}
+	}
+
+	if opts.MulticastTTL != nil {
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS, *opts.MulticastTTL)
+		} else {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, *opts.MulticastTTL)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	if opts.MulticastLoopback != nil {
+		loop := 0
+		if *opts.MulticastLoopback {
+			loop = 1
+		}
+
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_LOOP, loop)
+		} else {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_MULTICAST_LOOP, loop)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	if opts.MulticastInterface != nil {
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_IF, opts.MulticastInterface.Index)
+		} else {
+			err = syscall.SetsockoptIPMreqn(s, syscall.IPPROTO_IP, syscall.IP_MULTICAST_IF, &syscall.IPMreqn{Ifindex: int32(opts.MulticastInterface.Index)})
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	if opts.UDPPacketInfo {
+		if ipv6 {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IPV6, syscall.IPV6_RECVPKTINFO, 1)
+		} else {
+			err = syscall.SetsockoptInt(s, syscall.IPPROTO_IP, syscall.IP_PKTINFO, 1)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return setIPSockopts(s, ipv6, opts)
+}
+
+func setListenBacklog(fd uintptr, backlog int) error {
+	// listen(2) on a listening socket only updates its backlog
+	return syscall.Listen(int(fd), backlog)
+}
+
+// seconds rounds d up to whole seconds, the unit of the keepalive and defer accept options.
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
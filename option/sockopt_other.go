@@ -0,0 +1,36 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package option
+
+func setListenSockopts(fd uintptr, network string, opts *Options) error {
+	if opts.ReusePort || opts.TCPFastOpen > 0 || opts.TCPDeferAccept > 0 || opts.IPv6Only {
+		return ErrUnsupportedOption
+	}
+
+	return nil
+}
+
+func setDialSockopts(fd uintptr, network string, opts *Options) error {
+	if opts.TCPFastOpenConnect {
+		return ErrUnsupportedOption
+	}
+
+	return nil
+}
+
+func setConnSockopts(fd uintptr, ipv6 bool, opts *Options) error {
+	if needConnControl(opts) {
+		return ErrUnsupportedOption
+	}
+
+	return nil
+}
+
+func setUDPSockopts(fd uintptr, ipv6 bool, opts *Options) error {
+	return ErrUnsupportedOption
+}
+
+func setListenBacklog(fd uintptr, backlog int) error {
+	return ErrUnsupportedOption
+}
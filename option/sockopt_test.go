@@ -0,0 +1,49 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package option
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestSetupUDPOptions(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := SetupUDPOptions(conn, optionsOf(UDPBroadcast(true), MulticastTTL(0), MulticastLoopback(false), IPTOS(0x10), IPTTL(7))); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name       string
+		level, opt int
+		expected   int
+		anyNonZero bool
+	}{
+		{"SO_BROADCAST", syscall.SOL_SOCKET, syscall.SO_BROADCAST, 0, true},
+		{"IP_TOS", syscall.IPPROTO_IP, syscall.IP_TOS, 0x10, false},
+		{"IP_TTL", syscall.IPPROTO_IP, syscall.IP_TTL, 7, false},
+		{"IP_MULTICAST_TTL", syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, 0, false},
+	} {
+		var v int
+		err = Control(rc, func(fd uintptr) (err error) {
+			v, err = syscall.GetsockoptInt(int(fd), test.level, test.opt)
+			return
+		})
+
+		if err != nil || (test.anyNonZero && v == 0) || (!test.anyNonZero && v != test.expected) {
+			t.Fatalf("expected %s to be set, got %d %v", test.name, v, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package ngio
 
 import (
 	"errors"
+	"net"
 	"ngio/channel"
 	"ngio/listener"
 	"ngio/option"
@@ -33,6 +34,11 @@ func NewServer(network, laddr string) *Server {
 	}
 }
 
+// NewLocalServer creates a server bound to name inside the current process, see channel.LocalChannel.
+func NewLocalServer(name string) *Server {
+	return NewServer("local", name)
+}
+
 func (srv *Server) Option(opts ...option.Option) *Server {
 	for _, o := range opts {
 		o.Apply(srv.opts)
@@ -52,6 +58,8 @@ func (srv *Server) Serve() (err error) {
 		srv.lsn, err = listener.NewTCPListener(srv.network, srv.laddr, srv.opts, srv.initializer)
 	case "udp", "udp4", "udp6":
 		srv.lsn, err = listener.NewUDPListener(srv.network, srv.laddr, srv.opts, srv.initializer)
+	case "local":
+		srv.lsn, err = listener.NewLocalListener(srv.laddr, srv.opts, srv.initializer)
 	//case "ip", "ip4", "ip6":
 	default:
 		err = ErrUnsupportedNetwork
@@ -64,6 +72,16 @@ func (srv *Server) Serve() (err error) {
 	return srv.lsn.Serve()
 }
 
+// ServeListener serves the connections accepted by l instead of listening on the server's address.
+func (srv *Server) ServeListener(l net.Listener) (err error) {
+	srv.lsn, err = listener.FromListener(l, srv.opts, srv.initializer)
+	if err != nil {
+		return
+	}
+
+	return srv.lsn.Serve()
+}
+
 func (srv *Server) Shutdown() {
 	srv.lsn.Shutdown()
 }
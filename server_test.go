@@ -0,0 +1,73 @@
+package ngio
+
+import (
+	"net"
+	"ngio/channel"
+	"ngio/listener"
+	"ngio/option"
+	"testing"
+	"time"
+)
+
+type echoHandler struct{}
+
+func (echoHandler) ChannelRead(ctx *channel.Context, msg interface{}) {
+	ctx.Write(msg)
+}
+
+func TestServerServeListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer("tcp", "").Channel(func(ch channel.Channel) {
+		ch.Pipeline().AddLast("echo", echoHandler{})
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.ServeListener(l)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 4)
+	if n, err := conn.Read(b); err != nil || string(b[:n]) != "ping" {
+		t.Fatalf("expected ping, got %q, %v", b[:n], err)
+	}
+
+	srv.Shutdown()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ServeListener to return")
+	}
+}
+
+func TestServerServeListenerOptions(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the socket is already listening, its backlog can not be set anymore
+	if err := NewServer("tcp", "").Option(option.Backlog(128)).ServeListener(l); err != listener.ErrListenOption {
+		t.Fatalf("expected %v, got %v", listener.ErrListenOption, err)
+	}
+}